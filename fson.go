@@ -48,19 +48,84 @@
 //
 // For most use-cases the higher-level API will be enough. But there are examples, like multi-typed arrays,
 // where you will need to fall back to the lower level API to produce the desired output.
+//
+// The callbacks passed to methods like ObjectFunc, ArrayFunc and Lazy do not escape, so a closure
+// passed directly to them is not allocated on the heap.
 package fson
 
 import (
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
 	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf16"
 	"unicode/utf8"
+	"unsafe"
 )
 
 // Object represents a JSON object being constructed.
 // It maintains an internal byte buffer where the JSON is incrementally built up.
 type Object struct {
-	buf []byte
+	buf           []byte
+	err           error         // first error encountered while encoding, see Err()
+	utc           bool          // normalize times to UTC in the RFC 3339 encoders
+	complexFormat ComplexFormat // representation of complex numbers
+	decimalFormat DecimalFormat // representation of fixed-point decimals
+	mergeStrategy MergeStrategy // handling of duplicate keys in MergeObject
+	removals      int           // members removed by MergeKeepIncoming, which prevents rolling back past them
+
+	anyFallback func(*Object, any)    // encodes values of unsupported types in Any, see AnyFallback
+	lazyFilter  func(key string) bool // decides whether fields added with Lazy are written, see LazyFilter
+
+	// Containers that are currently open, excluding the top-level object. The first few are stored
+	// inline, so that building a shallow document in a fresh Object doesn't allocate.
+	depth int                        // number of open containers
+	stack [inlineStackSize]container // the outermost open containers
+	spill []container                // open containers beyond inlineStackSize
+
+	maxDepth         int    // maximum nesting depth, 0 means unlimited, see MaxDepth
+	depthPlaceholder string // replaces containers nested deeper than maxDepth
+
+	maxSize        int    // byte budget of the document, 0 means unlimited, see MaxSize
+	maxStringSize  int    // byte budget of a single string value, 0 means unlimited, see MaxStringSize
+	truncateSuffix string // appended to truncated string values
+	truncated      bool   // members were dropped because the document exceeded maxSize
+	member         member // member of the top-level object that is being written
+
+	fixed      bool   // fixed-capacity mode, see NewFixedObject
+	fixedBuf   []byte // the caller's buffer in fixed-capacity mode
+	overflowed bool   // a write did not fit in fixedBuf
+
+	stringWriter StringWriter // returned by StringWriter, kept here to avoid allocating it
+	base64Writer Base64Writer // returned by Base64Writer, kept here to avoid allocating it
+}
+
+// ObjectMarshaler is implemented by types that know how to encode themselves as the members
+// of a JSON object.
+//
+// MarshalFSON is called while the object is open, so implementations should only add key-value
+// pairs, for example:
+//
+//	func (e *HTTPError) MarshalFSON(o *fson.Object) error {
+//	    o.Int("status", e.Status).String("url", e.URL)
+//	    return nil
+//	}
+//
+// An error returned from MarshalFSON is recorded on the Object, see Err().
+type ObjectMarshaler interface {
+	MarshalFSON(o *Object) error
 }
 
 // NewObject creates a new JSON object builder using the provided byte buffer.
@@ -70,13 +135,703 @@ type Object struct {
 // to hold the complete JSON structure. If the buffer is too small, append
 // operations may cause reallocations, reducing performance benefits.
 func NewObject(buf []byte) *Object {
-	obj := &Object{
-		buf[:0], // Reset buffer
+	return new(Object).Init(buf)
+}
+
+// Init prepares o to build a new JSON object in the provided byte buffer, as if it was created
+// with NewObject. Any previous state and settings of o are discarded.
+//
+// Init makes it possible to use an Object as a value, for example as a local variable or as a
+// field of a larger struct, instead of going through the pointer returned by NewObject:
+//
+//	var obj fson.Object
+//	b := obj.Init(buf).String("hello", "world").Build()
+//
+// The Go compiler can usually keep an Object that doesn't outlive the function that declares it
+// on the stack, even if it is passed to helper functions. Methods that hand the Object to code the
+// compiler can't see, such as the callbacks of ObjectFunc and Lazy, an ObjectMarshaler or a
+// StringWriter, make it escape to the heap. Check with go build -gcflags=-m if in doubt.
+func (o *Object) Init(buf []byte) *Object {
+	*o = Object{buf: buf[:0]} // Reset buffer
+	o.buf = append(o.buf, '{')
+	return o
+}
+
+// ErrBufferFull is recorded when a write doesn't fit in the buffer of an Object created with NewFixedObject.
+var ErrBufferFull = errors.New("fson: buffer capacity exceeded")
+
+// NewFixedObject creates a new JSON object builder that never outgrows the provided byte buffer.
+// This makes it possible to build JSON in a preallocated array:
+//
+//	obj := fson.NewFixedObject(e.arr[:])
+//	b, err := obj.String("msg", msg).BuildErr()
+//	if errors.Is(err, fson.ErrBufferFull) {
+//	    // msg did not fit
+//	}
+//
+// Once a write doesn't fit in cap(buf) the Object is marked as overflowed, see Overflowed(), and
+// ErrBufferFull is recorded. Build then returns nil instead of a reallocated slice and BuildErr
+// reports ErrBufferFull. Further writes are discarded until the Object is Reset.
+//
+// Every write checks that it fits before it is appended, so the buffer is never reallocated, not even
+// by the write that overflows it. For values whose exact size isn't known upfront an upper bound is
+// checked, which can leave the last few bytes of the buffer unused. The only exception is the AppendText
+// method of a value passed to Text, which is free to allocate; if it outgrows the buffer its result is
+// discarded and the Object is marked as overflowed.
+func NewFixedObject(buf []byte) *Object {
+	return new(Object).InitFixed(buf)
+}
+
+// FixedArray is the set of arrays NewFixedObjectArray accepts a pointer to.
+type FixedArray interface {
+	*[64]byte | *[128]byte | *[256]byte | *[512]byte | *[1024]byte | *[2048]byte | *[4096]byte | *[8192]byte
+}
+
+// NewFixedObjectArray creates a new JSON object builder that never outgrows the provided array,
+// see NewFixedObject.
+//
+// Example:
+//
+//	b, err := fson.NewFixedObjectArray(&e.arr).String("msg", msg).BuildErr()
+//
+// The size of the buffer is part of its type, so it can't accidentally be a slice with less
+// capacity than intended. Note that the Object keeps a pointer to the array, so an array that is
+// declared locally is moved to the heap by the compiler; keep it next to the Object and reuse both.
+func NewFixedObjectArray[A FixedArray](arr A) *Object {
+	return NewFixedObject(unsafe.Slice((*byte)(unsafe.Pointer(arr)), len(arr)))
+}
+
+// InitFixed prepares o to build a new JSON object in the provided byte buffer, as if it was created
+// with NewFixedObject. Any previous state and settings of o are discarded. See Init.
+func (o *Object) InitFixed(buf []byte) *Object {
+	*o = Object{buf: buf[:0], fixed: true, fixedBuf: buf[:0:cap(buf)]}
+	if o.reserve(1) {
+		o.buf = append(o.buf, '{')
+	}
+	return o
+}
+
+// ErrNotObject is returned when bytes that are expected to hold a JSON object don't.
+var ErrNotObject = errors.New("fson: not a JSON object")
+
+// Reopen continues a JSON object that was already built, so more members can be appended to it
+// before calling Build again.
+//
+// Example:
+//
+//	body := handler.Encode() // {"user":"john"}
+//	obj, err := fson.Reopen(body)
+//	if err != nil {
+//	    return err
+//	}
+//	body = obj.String("request_id", id).Build()
+//	// Results in: {"user":"john","request_id":"..."}
+//
+// buf must hold a single valid JSON object, optionally surrounded by whitespace. If it doesn't,
+// ErrNotObject is returned.
+//
+// The reopened Object takes ownership of buf and appends to it like any other Object would.
+func Reopen(buf []byte) (*Object, error) {
+	o := new(Object)
+	if err := o.reopen(buf); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// reopen strips the closing brace, and any whitespace around it, from buf and continues it.
+func (o *Object) reopen(buf []byte) error {
+	if !validObject(buf) {
+		return ErrNotObject
+	}
+	start := skipSpace(buf, 0)
+	end := trimSpace(buf, len(buf))
+
+	// After the members of a non-empty object comes a comma, just like while it's being built.
+	end = trimSpace(buf, end-1)
+	*o = Object{buf: buf[:end]}
+	if end-1 != start {
+		o.buf = append(o.buf, ',')
+	}
+	return nil
+}
+
+// skipSpace returns the offset of the first byte in buf at or after i that isn't JSON whitespace.
+func skipSpace(buf []byte, i int) int {
+	for i < len(buf) && isSpace(buf[i]) {
+		i++
+	}
+	return i
+}
+
+// trimSpace returns the offset just after the last byte in buf before end that isn't JSON whitespace.
+func trimSpace(buf []byte, end int) int {
+	for end > 0 && isSpace(buf[end-1]) {
+		end--
+	}
+	return end
+}
+
+// isSpace reports whether c is JSON whitespace.
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+
+// Fragment holds a sequence of pre-encoded key-value pairs that can be added to any Object with
+// Object.Fragment, without encoding them again.
+//
+// Example:
+//
+//	ctx := fson.NewObject(nil).
+//	    String("service", "api").
+//	    String("env", "prod").
+//	    BuildFragment()
+//
+//	obj.Fragment(ctx).String("msg", "request handled")
+//	// Results in: {"service":"api","env":"prod","msg":"request handled"}
+//
+// A Fragment is immutable, so it can be shared between goroutines without synchronization.
+// The zero value is an empty Fragment.
+type Fragment struct {
+	b []byte // members, each followed by a comma
+}
+
+// Len returns the size of the encoded members in the Fragment.
+func (f Fragment) Len() int { return len(f.b) }
+
+// String returns the encoded members in the Fragment, separated by commas.
+func (f Fragment) String() string {
+	if len(f.b) == 0 {
+		return ""
+	}
+	return string(f.b[:len(f.b)-1])
+}
+
+// BuildFragment returns a Fragment holding a copy of the members that were added to the top-level object.
+//
+// Example:
+//
+//	base := fson.NewObject(nil).String("service", "api").BuildFragment()
+//	req := fson.NewObject(nil).Fragment(base).String("request_id", id).BuildFragment()
+//
+// The Object can be reset and reused afterwards. Calling BuildFragment while an object or array is
+// still open, while a key is waiting for its value, while a StringWriter or Base64Writer is open, or
+// after Build panics.
+//
+// With a MaxSize budget the members are dropped just like Build would drop them, and the fragment
+// ends with the "_truncated":true member if any were.
+func (o *Object) BuildFragment() Fragment {
+	switch {
+	case o.depth > 0:
+		panic("fson: BuildFragment called while an object or array is open")
+	case o.stringWriter.o != nil && !o.stringWriter.closed, o.base64Writer.o != nil && !o.base64Writer.closed:
+		panic("fson: BuildFragment called while a writer is open")
+	case len(o.buf) > 0 && o.buf[len(o.buf)-1] == ':':
+		panic("fson: BuildFragment called while a key is waiting for its value")
+	case len(o.buf) > 0 && o.buf[len(o.buf)-1] == '}':
+		panic("fson: BuildFragment called after Build")
+	}
+
+	const marker = `"_truncated":true,`
+	if o.maxSize > 0 {
+		o.endMember()
+	}
+	members := o.buf[skipSpace(o.buf, 0)+1:]
+	if !o.truncated {
+		return Fragment{b: slices.Clone(members)}
+	}
+	b := make([]byte, 0, len(members)+len(marker))
+	return Fragment{b: append(append(b, members...), marker...)}
+}
+
+// Fragment adds the members in f to the object that is currently open, with a single copy.
+//
+// Example:
+//
+//	obj.Fragment(requestContext).String("msg", "done")
+//
+// Calling Fragment while an array is the innermost open container panics.
+func (o *Object) Fragment(f Fragment) *Object {
+	if c := o.top(); c != nil && c.kind == containerArray {
+		panic("fson: Fragment called while an array is open")
+	}
+	if o.maxSize > 0 {
+		o.startMember() // the members of the fragment are dropped or kept as a whole
+	}
+	if o.fixed && !o.reserve(len(f.b)) {
+		return o
+	}
+
+	o.buf = append(o.buf, f.b...)
+	return o
+}
+
+// MergeStrategy selects how MergeObject handles keys that the current object already contains,
+// see MergeDuplicates.
+type MergeStrategy uint8
+
+const (
+	// MergeKeepBoth keeps existing and incoming members with the same key. The result contains duplicate
+	// keys, which most decoders resolve by using the last one. This is the default.
+	MergeKeepBoth MergeStrategy = iota
+	// MergeKeepExisting drops incoming members whose key the object already contains.
+	MergeKeepExisting
+	// MergeKeepIncoming removes existing members whose key is also in the merged object. Savepoints
+	// taken before such a removal can no longer be rolled back to, see Rollback.
+	MergeKeepIncoming
+)
+
+// MergeDuplicates sets how MergeObject handles keys that the current object already contains.
+//
+// Example:
+//
+//	obj.MergeDuplicates(fson.MergeKeepExisting).String("user", "john").MergeObject([]byte(`{"user":"eve","id":1}`))
+//	// Results in: {"user":"john","id":1}
+//
+// The setting is kept across calls to Reset().
+func (o *Object) MergeDuplicates(strategy MergeStrategy) *Object {
+	o.mergeStrategy = strategy
+	return o
+}
+
+// MergeObject adds the members of the JSON object in raw to the object that is currently open,
+// instead of nesting it under a key.
+//
+// Example:
+//
+//	obj.String("msg", "login").MergeObject([]byte(`{"user":"john","ip":"10.0.0.1"}`))
+//	// Results in: {"msg":"login","user":"john","ip":"10.0.0.1"}
+//
+// raw is validated first. If it isn't a single valid JSON object in valid UTF-8, nothing is added and
+// ErrNotObject is recorded, see Err(). Its members are copied as they are, whitespace inside values included,
+// unless MaxDepth or MaxStringSize is set: those limits apply to the merged values like to any other, and
+// the containers they are checked in are copied without whitespace. The merged members count as a single
+// member for MaxSize, they are dropped or kept as a whole.
+// Keys that the open object already contains are handled according to MergeDuplicates, keys are
+// compared after unescaping. Finding duplicates compares every incoming key with every existing one,
+// so merging large objects into large objects with anything but MergeKeepBoth is slow.
+//
+// Calling MergeObject while an array is the innermost open container panics.
+func (o *Object) MergeObject(raw []byte) *Object {
+	start := skipSpace(o.buf, 0) // offset of the opening brace of the open object
+	if c := o.top(); c != nil {
+		if c.kind == containerArray {
+			panic("fson: MergeObject called while an array is open")
+		}
+		start = c.start
+	}
+
+	if !validObject(raw) {
+		o.setErr(ErrNotObject)
+		return o
+	}
+
+	if o.maxSize > 0 {
+		o.startMember() // the merged members are dropped or kept as a whole
+	}
+	// The members are never longer than raw itself, which also holds the braces and all but one comma.
+	// Only the limits below can make them longer, so they are checked one by one.
+	limited := o.maxDepth > 0 || o.maxStringSize > 0
+	if o.fixed && !limited && !o.reserve(len(raw)) {
+		return o
+	}
+
+	existing := len(o.buf)
+	for i := skipSpace(raw, 0) + 1; ; {
+		key, value, next := nextMember(raw, i)
+		if next < 0 {
+			break
+		}
+		i = next
+
+		if o.mergeStrategy == MergeKeepExisting && hasKey(o.buf[:existing], start+1, existing, key) {
+			continue
+		}
+		if !limited {
+			o.buf = append(o.buf, key...)
+			o.buf = append(o.buf, ':')
+			o.buf = append(o.buf, value...)
+			o.buf = append(o.buf, ',')
+			continue
+		}
+		o.mergeBytes(key)
+		o.mergeBytes([]byte{':'})
+		o.mergeValue(value, o.depth+2)
+		o.mergeBytes([]byte{','})
+	}
+
+	if o.maxSize > 0 {
+		o.endMember()
+	}
+	if o.mergeStrategy == MergeKeepIncoming && len(o.buf) > existing {
+		o.removeMembers(start+1, existing, raw)
+	}
+	return o
+}
+
+// removeMembers removes the members of the open object between from and end whose key is also in the
+// JSON object raw, moving the rest of the buffer forward.
+func (o *Object) removeMembers(from, end int, raw []byte) {
+	rawStart := skipSpace(raw, 0) + 1
+	for i := from; ; {
+		key, _, next := nextMember(o.buf[:end], i)
+		if next < 0 {
+			return
+		}
+		next++ // every member is followed by a comma while the object is open
+		if !hasKey(raw, rawStart, len(raw), key) {
+			i = next
+			continue
+		}
+
+		o.buf = append(o.buf[:i], o.buf[next:]...)
+		end -= next - i
+		o.removals++
+	}
+}
+
+// mergeBytes appends b, unless it doesn't fit in fixed-capacity mode.
+func (o *Object) mergeBytes(b []byte) {
+	if !o.fixed || o.reserve(len(b)) {
+		o.buf = append(o.buf, b...)
+	}
+}
+
+// mergeValue appends the valid JSON value v, which ends up at the given depth, applying MaxDepth and
+// MaxStringSize to it.
+func (o *Object) mergeValue(v []byte, depth int) {
+	switch {
+	case v[0] == '"':
+		o.mergeString(v)
+		return
+	case v[0] != '{' && v[0] != '[':
+		o.mergeBytes(v)
+		return
+	case o.maxDepth > 0 && depth > o.maxDepth:
+		if o.depthPlaceholder == "" {
+			o.setErr(ErrMaxDepth)
+			o.mergeBytes([]byte("null"))
+			return
+		}
+		if !o.fixed || o.reserve(escapedLen(o.depthPlaceholder)+2) {
+			o.buf = appendString(o.buf, o.depthPlaceholder)
+		}
+		return
+	}
+
+	o.mergeBytes(v[:1])
+	for i, n := skipSpace(v, 1), 0; v[i] != '}' && v[i] != ']'; n++ {
+		if n > 0 {
+			o.mergeBytes([]byte{','})
+			i = skipSpace(v, i+1) // skip the comma
+		}
+		if v[0] == '{' {
+			key := v[i:scanString(v, i)]
+			o.mergeBytes(key)
+			o.mergeBytes([]byte{':'})
+			i = skipSpace(v, skipSpace(v, i+len(key))+1) // skip the colon
+		}
+		end := scanValue(v, i, 0)
+		o.mergeValue(v[i:end], depth+1)
+		i = skipSpace(v, end)
+	}
+	o.mergeBytes(v[len(v)-1:])
+}
+
+// mergeString appends the valid quoted JSON string s, cut off at the MaxStringSize limit like
+// appendTruncatedString. The limit is measured on the unescaped string.
+func (o *Object) mergeString(s []byte) {
+	keep, kept, size := 0, 0, 0 // offset in s to cut at and the unescaped size before and after it
+	for i := 1; i < len(s)-1; {
+		r, next := nextStringRune(s, i)
+		n := utf8.RuneLen(r)
+		if keep == 0 && o.maxStringSize > 0 && size+n > o.maxStringSize {
+			keep, kept = i, size
+		}
+		size += n
+		i = next
+	}
+	if keep == 0 {
+		o.mergeBytes(s)
+		return
+	}
+
+	if !o.fixed || o.reserve(keep+escapedLen(o.truncateSuffix)+maxIntLen+1) {
+		o.buf = append(o.buf, s[:keep]...)
+		o.buf = appendTruncateSuffix(o.buf, o.truncateSuffix, size-kept)
+		o.buf = append(o.buf, '"')
+	}
+}
+
+// maxMergeDepth is the deepest nesting of arrays and objects MergeObject accepts.
+const maxMergeDepth = 10000
+
+// validObject reports whether b holds a single valid JSON object, optionally surrounded by whitespace.
+func validObject(b []byte) bool {
+	i := skipSpace(b, 0)
+	if i == len(b) || b[i] != '{' || !utf8.Valid(b) {
+		return false
+	}
+	i = scanValue(b, i, 0)
+	return i >= 0 && skipSpace(b, i) == len(b)
+}
+
+// scanValue returns the offset just after the JSON value that starts at i in b, or -1 if it isn't valid.
+func scanValue(b []byte, i, depth int) int { //nolint: cyclop
+	if i >= len(b) {
+		return -1
+	}
+
+	switch c := b[i]; {
+	case c == '"':
+		return scanString(b, i)
+	case c == '-' || c >= '0' && c <= '9':
+		return scanNumber(b, i)
+	case c == 't':
+		return scanLiteral(b, i, "true")
+	case c == 'f':
+		return scanLiteral(b, i, "false")
+	case c == 'n':
+		return scanLiteral(b, i, "null")
+	case c != '{' && c != '[':
+		return -1
+	}
+
+	if depth >= maxMergeDepth {
+		return -1
+	}
+	closing := byte('}')
+	if b[i] == '[' {
+		closing = ']'
+	}
+
+	i = skipSpace(b, i+1)
+	if i < len(b) && b[i] == closing {
+		return i + 1
+	}
+	for {
+		if closing == '}' {
+			if i >= len(b) || b[i] != '"' {
+				return -1
+			}
+			if i = scanString(b, i); i < 0 {
+				return -1
+			}
+			if i = skipSpace(b, i); i >= len(b) || b[i] != ':' {
+				return -1
+			}
+			i = skipSpace(b, i+1)
+		}
+		if i = scanValue(b, i, depth+1); i < 0 {
+			return -1
+		}
+
+		switch i = skipSpace(b, i); {
+		case i >= len(b):
+			return -1
+		case b[i] == closing:
+			return i + 1
+		case b[i] != ',':
+			return -1
+		}
+		i = skipSpace(b, i+1)
+	}
+}
+
+// scanString returns the offset just after the JSON string that starts at i in b, or -1 if it isn't valid.
+func scanString(b []byte, i int) int {
+	for i++; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '"':
+			return i + 1
+		case c < 0x20:
+			return -1
+		case c == '\\':
+			if i++; i >= len(b) {
+				return -1
+			}
+			switch b[i] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				if i+4 >= len(b) {
+					return -1
+				}
+				for _, h := range b[i+1 : i+5] {
+					if _, ok := unhex(h); !ok {
+						return -1
+					}
+				}
+				i += 4
+			default:
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// scanNumber returns the offset just after the JSON number that starts at i in b, or -1 if it isn't valid.
+func scanNumber(b []byte, i int) int {
+	if b[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(b) && b[i] == '0':
+		i++
+	case i < len(b) && b[i] >= '1' && b[i] <= '9':
+		i = scanDigits(b, i)
+	default:
+		return -1
+	}
+
+	if i < len(b) && b[i] == '.' {
+		if i = scanDigits(b, i+1); i < 0 {
+			return -1
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		if i = scanDigits(b, i); i < 0 {
+			return -1
+		}
+	}
+	return i
+}
+
+// scanDigits returns the offset just after the digits that start at i in b, or -1 if there are none.
+func scanDigits(b []byte, i int) int {
+	start := i
+	for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+		i++
+	}
+	if i == start {
+		return -1
+	}
+	return i
+}
+
+// scanLiteral returns the offset just after lit if b contains it at i, or -1 if it doesn't.
+func scanLiteral(b []byte, i int, lit string) int {
+	if len(b)-i < len(lit) || string(b[i:i+len(lit)]) != lit {
+		return -1
+	}
+	return i + len(lit)
+}
+
+// nextMember returns the quoted key and the value of the member of a JSON object that starts at or
+// after i in b, skipping a separating comma, and the offset just after it. next is -1 if there are
+// no more valid members.
+func nextMember(b []byte, i int) (key, value []byte, next int) {
+	i = skipSpace(b, i)
+	if i < len(b) && b[i] == ',' {
+		i = skipSpace(b, i+1)
+	}
+	if i >= len(b) || b[i] != '"' {
+		return nil, nil, -1
+	}
+
+	end := scanString(b, i)
+	if end < 0 {
+		return nil, nil, -1
+	}
+	key = b[i:end]
+	i = skipSpace(b, skipSpace(b, end)+1) // skip the colon
+	if end = scanValue(b, i, 0); end < 0 {
+		return nil, nil, -1
+	}
+	return key, b[i:end], end
+}
+
+// hasKey reports whether the members of a JSON object in b, between start and end, include key.
+func hasKey(b []byte, start, end int, key []byte) bool {
+	b = b[:end]
+	for i := start; ; {
+		k, _, next := nextMember(b, i)
+		if next < 0 {
+			return false
+		}
+		if equalKeys(k, key) {
+			return true
+		}
+		i = next
+	}
+}
+
+// equalKeys reports whether the quoted JSON strings a and b hold the same text once unescaped.
+func equalKeys(a, b []byte) bool {
+	if string(a) == string(b) {
+		return true
+	}
+
+	i, j := 1, 1
+	for i < len(a)-1 && j < len(b)-1 {
+		var ra, rb rune
+		ra, i = nextStringRune(a, i)
+		rb, j = nextStringRune(b, j)
+		if ra != rb {
+			return false
+		}
+	}
+	return i == len(a)-1 && j == len(b)-1
+}
+
+// nextStringRune decodes the character at offset i of the valid quoted JSON string s and returns it,
+// together with the offset of the next character.
+func nextStringRune(s []byte, i int) (rune, int) {
+	if s[i] != '\\' {
+		r, n := utf8.DecodeRune(s[i:])
+		return r, i + n
+	}
+
+	switch c := s[i+1]; c {
+	case 'b':
+		return '\b', i + 2
+	case 'f':
+		return '\f', i + 2
+	case 'n':
+		return '\n', i + 2
+	case 'r':
+		return '\r', i + 2
+	case 't':
+		return '\t', i + 2
+	case 'u':
+		r := hex4(s[i+2 : i+6])
+		if utf16.IsSurrogate(r) && i+12 <= len(s) && s[i+6] == '\\' && s[i+7] == 'u' {
+			if r = utf16.DecodeRune(r, hex4(s[i+8:i+12])); r != utf8.RuneError {
+				return r, i + 12
+			}
+		}
+		if utf16.IsSurrogate(r) {
+			r = utf8.RuneError
+		}
+		return r, i + 6
+	default: // '"', '\\' and '/' stand for themselves
+		return rune(c), i + 2
 	}
+}
 
-	obj.buf = append(obj.buf, '{')
+// hex4 decodes the four hexadecimal digits in b.
+func hex4(b []byte) rune {
+	var r rune
+	for _, c := range b[:4] {
+		v, _ := unhex(c)
+		r = r<<4 | rune(v)
+	}
+	return r
+}
 
-	return obj
+// unhex decodes a single hexadecimal digit.
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
 }
 
 // Key appends a key to the JSON object and prepares for a value to be added.
@@ -94,6 +849,12 @@ func NewObject(buf []byte) *Object {
 // you should call one of the Value methods (StringValue, IntValue, etc.) to add
 // the corresponding value for this key.
 func (o *Object) Key(key string) *Object {
+	if o.maxSize > 0 {
+		o.startMember()
+	}
+	if o.fixed && !o.reserve(escapedLen(key)+3) {
+		return o
+	}
 	o.buf = appendString(o.buf, key)
 	o.buf = append(o.buf, ':')
 	return o
@@ -124,6 +885,9 @@ func (o *Object) Null(key string) *Object {
 // This method should be used after calling Key() when you want to explicitly
 // set a value to null rather than omitting the field entirely.
 func (o *Object) NullValue() *Object {
+	if o.fixed && !o.reserve(len("null,")) {
+		return o
+	}
 	o.buf = append(o.buf, "null"...)
 	o.buf = append(o.buf, ',')
 	return o
@@ -144,7 +908,26 @@ func (o *Object) String(key, value string) *Object {
 //
 //	obj.Key("name").StringValue("John Doe")
 func (o *Object) StringValue(value string) *Object {
-	o.buf = appendString(o.buf, value)
+	n := len(value)
+	if o.maxStringSize > 0 {
+		n = min(n, o.maxStringSize)
+	}
+	if o.overBudget(n) {
+		// The member is dropped anyway, so don't copy the string into the buffer
+		if !o.fixed || o.reserve(3) {
+			o.buf = append(o.buf, '"', '"', ',')
+		}
+		return o
+	}
+	if o.fixed && !o.reserve(o.stringLen(value)+1) {
+		return o
+	}
+
+	if o.maxStringSize > 0 {
+		o.buf = appendTruncatedString(o.buf, value, o.maxStringSize, o.truncateSuffix)
+	} else {
+		o.buf = appendString(o.buf, value)
+	}
 	o.buf = append(o.buf, ',')
 	return o
 }
@@ -164,28 +947,384 @@ func (o *Object) Strings(key string, value []string) *Object {
 //
 //	obj.Key("tags").StringsValue([]string{"json", "encoder", "go"})
 func (o *Object) StringsValue(value []string) *Object {
-	o.buf = appendArray(o.buf, value, appendString)
-	o.buf = append(o.buf, ',')
-	return o
+	if o.maxStringSize > 0 {
+		return appendArrayValue(o, value, o.stringLen, func(buf []byte, v string) []byte {
+			return appendTruncatedString(buf, v, o.maxStringSize, o.truncateSuffix)
+		})
+	}
+	return appendArrayValue(o, value, o.stringLen, appendString)
 }
 
-// Int appends an integer key-value pair to the JSON object.
-// This is a convenience wrapper around Int64.
+// StringWriter starts a string value with the given key and returns a writer that appends the
+// escaped bytes written to it to the JSON object.
 //
 // Example:
 //
-//	obj.Int("count", 42)
-func (o *Object) Int(key string, value int) *Object {
-	return o.Key(key).IntValue(value)
+//	w := obj.StringWriter("body")
+//	_, _ = io.Copy(w, file)
+//	_ = w.Close()
+//	// Results in: {"body":"...escaped contents of file..."}
+//
+// This allows large text values to be streamed into the JSON object without first materializing
+// them as a string. UTF-8 sequences that are split across writes are handled correctly.
+//
+// IMPORTANT: The writer must be closed before anything else is added to the object, Close()
+// writes the closing quote. Only one StringWriter can be open at a time per Object, the returned
+// writer is reused by the next call to StringWriter.
+func (o *Object) StringWriter(key string) *StringWriter {
+	return o.Key(key).StringWriterValue()
 }
 
-// IntValue appends an integer value to the current key in the JSON object.
-// This is a convenience wrapper around Int64Value.
+// StringWriterValue starts a string value for the current key in the JSON object and returns a writer
+// that appends the escaped bytes written to it.
 //
 // Example:
 //
-//	obj.Key("count").IntValue(42)
-func (o *Object) IntValue(value int) *Object {
+//	w := obj.Key("body").StringWriterValue()
+//	_, _ = w.Write(chunk)
+//	_ = w.Close()
+//
+// See StringWriter for details.
+func (o *Object) StringWriterValue() *StringWriter {
+	if !o.fixed || o.reserve(1) {
+		o.buf = append(o.buf, '"')
+	}
+	o.stringWriter = StringWriter{o: o, limit: o.maxStringSize}
+	return &o.stringWriter
+}
+
+// ReadStringFrom appends everything read from r until io.EOF as a string key-value pair to the JSON object.
+//
+// Example:
+//
+//	obj.ReadStringFrom("body", req.Body)
+//
+// The contents are read straight into the underlying buffer and escaped in place afterwards.
+// If reading fails the string is closed with whatever was read so far and the error is recorded, see Err().
+func (o *Object) ReadStringFrom(key string, r io.Reader) *Object {
+	return o.Key(key).ReadStringFromValue(r)
+}
+
+// ReadStringFromValue appends everything read from r until io.EOF as a string value to the current key
+// in the JSON object.
+//
+// Example:
+//
+//	obj.Key("body").ReadStringFromValue(req.Body)
+func (o *Object) ReadStringFromValue(r io.Reader) *Object {
+	if o.fixed && !o.reserve(1) {
+		return o
+	}
+	start := len(o.buf)
+	o.buf = append(o.buf, '"')
+
+	// Bytes past the limits are read but not kept, only a few extra bytes are kept to cut the string
+	// at a UTF-8 boundary.
+	keep := math.MaxInt
+	if o.maxStringSize > 0 {
+		keep = start + 1 + o.maxStringSize + utf8.UTFMax
+	}
+	dropped := 0
+
+	for {
+		if len(o.buf) == cap(o.buf) {
+			if o.fixed {
+				// Don't read any further than fits
+				o.overflow()
+				return o
+			}
+			o.buf = slices.Grow(o.buf, readChunkSize)
+		}
+
+		n, err := r.Read(o.buf[len(o.buf):cap(o.buf)])
+		o.buf = o.buf[:len(o.buf)+n]
+		if o.overBudget(0) {
+			keep = start + 1
+		}
+		if len(o.buf) > keep {
+			dropped += len(o.buf) - keep
+			o.buf = o.buf[:keep]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			o.setErr(err)
+			break
+		}
+	}
+
+	o.closeStringValue(start, dropped)
+	return o
+}
+
+// readChunkSize is the minimum amount of free space ReadStringFrom makes available for each read.
+const readChunkSize = 512
+
+// ErrWriterClosed is returned when writing to a StringWriter that was already closed.
+var ErrWriterClosed = errors.New("fson: write to closed writer")
+
+// StringWriter is an io.WriteCloser that appends the bytes written to it as an escaped JSON string
+// to an Object. It is obtained by calling StringWriter() or StringWriterValue() on an Object.
+type StringWriter struct {
+	o       *Object
+	pending [utf8.UTFMax]byte // start of a UTF-8 sequence that was split across writes
+	n       int               // number of bytes in pending
+	limit   int               // MaxStringSize at the time the writer was started
+	size    int               // number of bytes that were written so far, before escaping
+	dropped int               // number of bytes that were cut off
+	closed  bool
+}
+
+// Write escapes p and appends it to the string value. It always consumes all of p.
+func (w *StringWriter) Write(p []byte) (int, error) {
+	if w.o == nil || w.closed {
+		return 0, ErrWriterClosed
+	}
+	written := len(p)
+
+	// First complete the sequence that was split over the previous write
+	for w.n > 0 && len(p) > 0 {
+		// Join the sequence in pending, which is never longer than a single rune
+		k := copy(w.pending[w.n:], p)
+		seq := w.pending[:w.n+k]
+
+		if !utf8.FullRune(seq) {
+			// Still not enough bytes, p is too short to complete the sequence
+			w.n += k
+			return written, nil
+		}
+
+		_, size := utf8.DecodeRune(seq)
+		if size <= w.n {
+			// The pending bytes turned out to be invalid, escape them and retry with the remainder
+			w.o.buf = w.append(w.o.buf, seq[:size])
+			w.n = copy(w.pending[:], w.pending[size:w.n])
+			continue
+		}
+
+		w.o.buf = w.append(w.o.buf, seq[:size])
+		p = p[size-w.n:]
+		w.n = 0
+	}
+
+	// Hold back an incomplete sequence at the end of p until the next write
+	end := len(p)
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+
+	w.o.buf = w.append(w.o.buf, p[:end])
+	w.n += copy(w.pending[w.n:], p[end:])
+	return written, nil
+}
+
+// append escapes the complete UTF-8 sequences in p and appends them to buf, up to the MaxStringSize
+// limit. Nothing is appended anymore once the member doesn't fit in the MaxSize budget.
+func (w *StringWriter) append(buf, p []byte) []byte {
+	if w.dropped > 0 {
+		w.dropped += len(p)
+		return buf
+	}
+	if w.limit > 0 && w.size+len(p) > w.limit {
+		n := w.limit - w.size
+		for n > 0 && !utf8.RuneStart(p[n]) {
+			n--
+		}
+		w.dropped = len(p) - n
+		p = p[:n]
+	}
+	if w.o.overBudget(len(p)) || w.o.fixed && !w.o.reserve(escapedLen(p)) {
+		w.dropped += len(p)
+		return buf
+	}
+
+	w.size += len(p)
+	return safeAppendString(utf8.DecodeRune, buf, p)
+}
+
+// Close finishes the string value by writing the closing quote. An incomplete UTF-8 sequence left
+// over from the last write is replaced by the UTF-8 replacement character.
+// Calling Close more than once has no effect.
+func (w *StringWriter) Close() error {
+	if w.o == nil || w.closed {
+		return nil
+	}
+	w.finish(w.o)
+	return nil
+}
+
+// finish appends the pending bytes and the truncation suffix to the string in o and closes it.
+// It doesn't pass w on, so that closing the writer from CloseAll doesn't make the Object escape.
+func (w *StringWriter) finish(o *Object) {
+	w.closed = true
+
+	// pending only ever holds an incomplete UTF-8 sequence, each byte of which is replaced.
+	replaced := w.n
+	if w.dropped > 0 {
+		replaced = 0
+	} else if w.limit > 0 {
+		replaced = min(w.n, w.limit-w.size)
+	}
+	w.dropped += w.n - replaced
+	w.size += replaced
+	w.n = 0
+
+	n := replaced*utf8.RuneLen(utf8.RuneError) + 2
+	if w.dropped > 0 && w.limit > 0 {
+		n += escapedLen(o.truncateSuffix) + maxIntLen
+	}
+	if o.fixed && !o.reserve(n) {
+		return
+	}
+
+	for range replaced {
+		o.buf = utf8.AppendRune(o.buf, utf8.RuneError)
+	}
+	if w.dropped > 0 && w.limit > 0 {
+		o.buf = appendTruncateSuffix(o.buf, o.truncateSuffix, w.dropped)
+	}
+	o.buf = append(o.buf, '"', ',')
+}
+
+// Base64Writer starts a string value with the given key and returns a writer that appends the
+// bytes written to it as base64 using the given encoding.
+//
+// Example:
+//
+//	w := obj.Base64Writer("attachment", base64.StdEncoding)
+//	_, _ = io.Copy(w, file)
+//	_ = w.Close()
+//	// Results in: {"attachment":"...base64 encoded contents of file..."}
+//
+// This allows large binary payloads to be embedded without first encoding them into a temporary
+// string. Typically enc is one of base64.StdEncoding, base64.URLEncoding or their Raw variants
+// without padding. Any custom encoding must use an alphabet that does not need escaping in JSON.
+//
+// IMPORTANT: The writer must be closed before anything else is added to the object, Close()
+// flushes the last partial block including padding and writes the closing quote. Only one
+// Base64Writer can be open at a time per Object, the returned writer is reused by the next call
+// to Base64Writer.
+func (o *Object) Base64Writer(key string, enc *base64.Encoding) *Base64Writer {
+	return o.Key(key).Base64WriterValue(enc)
+}
+
+// Base64WriterValue starts a string value for the current key in the JSON object and returns a writer
+// that appends the bytes written to it as base64 using the given encoding.
+//
+// Example:
+//
+//	w := obj.Key("attachment").Base64WriterValue(base64.URLEncoding)
+//	_, _ = w.Write(data)
+//	_ = w.Close()
+//
+// See Base64Writer for details.
+func (o *Object) Base64WriterValue(enc *base64.Encoding) *Base64Writer {
+	if !o.fixed || o.reserve(1) {
+		o.buf = append(o.buf, '"')
+	}
+	o.base64Writer = Base64Writer{o: o, enc: enc}
+	return &o.base64Writer
+}
+
+// Base64Writer is an io.WriteCloser that appends the bytes written to it as a base64 encoded JSON
+// string to an Object. It is obtained by calling Base64Writer() or Base64WriterValue() on an Object.
+type Base64Writer struct {
+	o       *Object
+	enc     *base64.Encoding
+	pending [2]byte // bytes that did not make up a complete 3 byte block yet
+	n       int     // number of bytes in pending
+	dropped bool    // the value no longer fits in the MaxSize budget, nothing is encoded anymore
+	closed  bool
+}
+
+// Write encodes p and appends it to the string value. It always consumes all of p.
+//
+// Only complete blocks of 3 bytes are encoded, the remaining bytes are held back until the
+// next call to Write or Close.
+func (w *Base64Writer) Write(p []byte) (int, error) {
+	if w.o == nil || w.closed {
+		return 0, ErrWriterClosed
+	}
+	written := len(p)
+
+	// Complete the block that was started by the previous write
+	if w.n > 0 {
+		var block [3]byte
+		copy(block[:], w.pending[:w.n])
+		k := copy(block[w.n:], p)
+		if w.n+k < len(block) {
+			w.n += copy(w.pending[w.n:], p)
+			return written, nil
+		}
+		w.encode(w.o, block[:])
+		p = p[k:]
+		w.n = 0
+	}
+
+	full := len(p) - len(p)%3
+	w.encode(w.o, p[:full])
+	w.n = copy(w.pending[:], p[full:])
+	return written, nil
+}
+
+// encode appends p to the string in o. Once the member doesn't fit in the MaxSize budget nothing is
+// appended anymore, the member is dropped as a whole when it ends.
+func (w *Base64Writer) encode(o *Object, p []byte) {
+	n := w.enc.EncodedLen(len(p))
+	if w.dropped || o.overBudget(n) || o.fixed && !o.reserve(n) {
+		w.dropped = true
+		return
+	}
+	o.buf = w.enc.AppendEncode(o.buf, p)
+}
+
+// Close encodes the bytes that are still held back, including padding if the encoding uses it,
+// and finishes the string value by writing the closing quote.
+// Calling Close more than once has no effect.
+func (w *Base64Writer) Close() error {
+	if w.o == nil || w.closed {
+		return nil
+	}
+	w.finish(w.o)
+	return nil
+}
+
+// finish encodes the bytes that are still held back and closes the string in o.
+// It doesn't pass w on, so that closing the writer from CloseAll doesn't make the Object escape.
+func (w *Base64Writer) finish(o *Object) {
+	w.closed = true
+
+	w.encode(o, w.pending[:w.n])
+	w.n = 0
+	if o.fixed && !o.reserve(2) {
+		return
+	}
+	o.buf = append(o.buf, '"', ',')
+}
+
+// Int appends an integer key-value pair to the JSON object.
+// This is a convenience wrapper around Int64.
+//
+// Example:
+//
+//	obj.Int("count", 42)
+func (o *Object) Int(key string, value int) *Object {
+	return o.Key(key).IntValue(value)
+}
+
+// IntValue appends an integer value to the current key in the JSON object.
+// This is a convenience wrapper around Int64Value.
+//
+// Example:
+//
+//	obj.Key("count").IntValue(42)
+func (o *Object) IntValue(value int) *Object {
 	return o.Int64Value(int64(value))
 }
 
@@ -204,11 +1343,7 @@ func (o *Object) Ints(key string, value []int) *Object {
 //
 //	obj.Key("values").IntsValue([]int{1, 2, 3, 4, 5})
 func (o *Object) IntsValue(value []int) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value int) []byte {
-		return strconv.AppendInt(buf, int64(value), 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return IntsValue(o, value)
 }
 
 // Int8 appends an int8 key-value pair to the JSON object.
@@ -246,11 +1381,7 @@ func (o *Object) Ints8(key string, value []int8) *Object {
 //
 //	obj.Key("values").Ints8Value([]int8{1, 2, 3, 4, 5})
 func (o *Object) Ints8Value(value []int8) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value int8) []byte {
-		return strconv.AppendInt(buf, int64(value), 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return IntsValue(o, value)
 }
 
 // Int16 appends an int16 key-value pair to the JSON object.
@@ -288,11 +1419,7 @@ func (o *Object) Ints16(key string, value []int16) *Object {
 //
 //	obj.Key("values").Ints16Value([]int16{1, 2, 3, 4, 5})
 func (o *Object) Ints16Value(value []int16) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value int16) []byte {
-		return strconv.AppendInt(buf, int64(value), 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return IntsValue(o, value)
 }
 
 // Int32 appends an int32 key-value pair to the JSON object.
@@ -330,11 +1457,7 @@ func (o *Object) Ints32(key string, value []int32) *Object {
 //
 //	obj.Key("values").Ints32Value([]int32{1, 2, 3, 4, 5})
 func (o *Object) Ints32Value(value []int32) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value int32) []byte {
-		return strconv.AppendInt(buf, int64(value), 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return IntsValue(o, value)
 }
 
 // Int64 appends an int64 key-value pair to the JSON object.
@@ -354,6 +1477,9 @@ func (o *Object) Int64(key string, value int64) *Object {
 //
 //	obj.Key("value").Int64Value(42)
 func (o *Object) Int64Value(value int64) *Object {
+	if o.fixed && !o.reserve(intLen(value)+1) {
+		return o
+	}
 	o.buf = strconv.AppendInt(o.buf, value, 10)
 	o.buf = append(o.buf, ',')
 	return o
@@ -374,11 +1500,7 @@ func (o *Object) Ints64(key string, value []int64) *Object {
 //
 //	obj.Key("values").Ints64Value([]int64{1, 2, 3, 4, 5})
 func (o *Object) Ints64Value(value []int64) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value int64) []byte {
-		return strconv.AppendInt(buf, value, 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return IntsValue(o, value)
 }
 
 // Uint appends an unsigned integer key-value pair to the JSON object.
@@ -416,11 +1538,7 @@ func (o *Object) Uints(key string, value []uint) *Object {
 //
 //	obj.Key("values").UintsValue([]uint{1, 2, 3, 4, 5})
 func (o *Object) UintsValue(value []uint) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value uint) []byte {
-		return strconv.AppendUint(buf, uint64(value), 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return UintsValue(o, value)
 }
 
 // Uint8 appends a uint8 key-value pair to the JSON object.
@@ -458,11 +1576,7 @@ func (o *Object) Uints8(key string, value []uint8) *Object {
 //
 //	obj.Key("values").Uints8Value([]uint8{1, 2, 3, 4, 5})
 func (o *Object) Uints8Value(value []uint8) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value uint8) []byte {
-		return strconv.AppendUint(buf, uint64(value), 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return UintsValue(o, value)
 }
 
 // Uint16 appends a uint16 key-value pair to the JSON object.
@@ -500,11 +1614,7 @@ func (o *Object) Uints16(key string, value []uint16) *Object {
 //
 //	obj.Key("values").Uints16Value([]uint16{1, 2, 3, 4, 5})
 func (o *Object) Uints16Value(value []uint16) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value uint16) []byte {
-		return strconv.AppendUint(buf, uint64(value), 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return UintsValue(o, value)
 }
 
 // Uint32 appends a uint32 key-value pair to the JSON object.
@@ -542,11 +1652,7 @@ func (o *Object) Uints32(key string, value []uint32) *Object {
 //
 //	obj.Key("values").Uints32Value([]uint32{1, 2, 3, 4, 5})
 func (o *Object) Uints32Value(value []uint32) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value uint32) []byte {
-		return strconv.AppendUint(buf, uint64(value), 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return UintsValue(o, value)
 }
 
 // Uint64 appends a uint64 key-value pair to the JSON object.
@@ -566,6 +1672,9 @@ func (o *Object) Uint64(key string, value uint64) *Object {
 //
 //	obj.Key("value").Uint64Value(42)
 func (o *Object) Uint64Value(value uint64) *Object {
+	if o.fixed && !o.reserve(uintLen(value)+1) {
+		return o
+	}
 	o.buf = strconv.AppendUint(o.buf, value, 10)
 	o.buf = append(o.buf, ',')
 	return o
@@ -586,11 +1695,7 @@ func (o *Object) Uints64(key string, value []uint64) *Object {
 //
 //	obj.Key("values").Uints64Value([]uint64{1, 2, 3, 4, 5})
 func (o *Object) Uints64Value(value []uint64) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value uint64) []byte {
-		return strconv.AppendUint(buf, value, 10)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return UintsValue(o, value)
 }
 
 // Float32 appends a float32 key-value pair to the JSON object.
@@ -660,11 +1765,7 @@ func (o *Object) Floats32(key string, value []float32) *Object {
 // Note: Special values like NaN and Infinity will be encoded as string values
 // rather than JSON numbers, as JSON does not support these values as numbers.
 func (o *Object) Floats32Value(value []float32) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value float32) []byte {
-		return appendFloat(buf, float64(value), 32)
-	})
-	o.buf = append(o.buf, ',')
-	return o
+	return FloatsValue(o, value)
 }
 
 // Float64 appends a float64 key-value pair to the JSON object.
@@ -690,6 +1791,9 @@ func (o *Object) Float64(key string, value float64) *Object {
 // Note: Special values like NaN and Infinity will be encoded as string values
 // rather than JSON numbers, as JSON does not support these values as numbers.
 func (o *Object) Float64Value(value float64) *Object {
+	if o.fixed && !o.reserve(floatLen(value)+1) {
+		return o
+	}
 	o.buf = appendFloat(o.buf, value, 64)
 	o.buf = append(o.buf, ',')
 	return o
@@ -736,320 +1840,2414 @@ func (o *Object) Floats64(key string, value []float64) *Object {
 // Note: Special values like NaN and Infinity will be encoded as string values
 // rather than JSON numbers, as JSON does not support these values as numbers.
 func (o *Object) Floats64Value(value []float64) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value float64) []byte {
-		return appendFloat(buf, value, 64)
-	})
-	o.buf = append(o.buf, ',')
+	return FloatsValue(o, value)
+}
+
+// ComplexFormat selects how complex numbers are represented in JSON, see EncodeComplexAs.
+type ComplexFormat uint8
+
+const (
+	// ComplexObject encodes complex numbers as an object with a real and imaginary part: {"re":1,"im":2}.
+	// This is the default.
+	ComplexObject ComplexFormat = iota
+	// ComplexArray encodes complex numbers as a two element array with the real and imaginary part: [1,2].
+	ComplexArray
+	// ComplexString encodes complex numbers as a string in Go syntax without parentheses: "1+2i".
+	ComplexString
+)
+
+// EncodeComplexAs sets the representation used by the Complex64 and Complex128 methods and their
+// Value and slice variants.
+//
+// Example:
+//
+//	obj.EncodeComplexAs(fson.ComplexArray).Complex128("z", 1+2i)
+//	// Results in: {"z":[1,2]}
+//
+// The setting is kept across calls to Reset().
+func (o *Object) EncodeComplexAs(format ComplexFormat) *Object {
+	o.complexFormat = format
 	return o
 }
 
-// Bool appends a boolean key-value pair to the JSON object.
+// Complex64 appends a complex64 key-value pair to the JSON object.
+// This is a convenience wrapper around Complex128.
 //
 // Example:
 //
-//	obj.Bool("active", true)
-func (o *Object) Bool(key string, value bool) *Object {
-	return o.Key(key).BoolValue(value)
+//	obj.Complex64("z", 1+2i) // Encodes as "z":{"re":1,"im":2}
+//
+// Note: Special values like NaN and Infinity in either part follow the same rules as Float64.
+func (o *Object) Complex64(key string, value complex64) *Object {
+	return o.Key(key).Complex64Value(value)
 }
 
-// BoolValue appends a boolean value to the current key in the JSON object.
+// Complex64Value appends a complex64 value to the current key in the JSON object.
 //
 // Example:
 //
-//	obj.Key("active").BoolValue(true)
-func (o *Object) BoolValue(value bool) *Object {
-	o.buf = strconv.AppendBool(o.buf, value)
+//	obj.Key("z").Complex64Value(1+2i)
+func (o *Object) Complex64Value(value complex64) *Object {
+	if o.fixed && !o.reserve(complexLen(complex128(value))+1) {
+		return o
+	}
+	o.buf = appendComplex(o.buf, complex128(value), 32, o.complexFormat)
 	o.buf = append(o.buf, ',')
 	return o
 }
 
-// Bools appends an array of boolean values as a key-value pair to the JSON object.
+// Complexes64 appends an array of complex64 values as a key-value pair to the JSON object.
 //
 // Example:
 //
-//	obj.Bools("flags", []bool{true, false, true})
-func (o *Object) Bools(key string, value []bool) *Object {
-	return o.Key(key).BoolsValue(value)
+//	obj.Complexes64("samples", []complex64{1+2i, 3-4i})
+func (o *Object) Complexes64(key string, value []complex64) *Object {
+	return o.Key(key).Complexes64Value(value)
 }
 
-// BoolsValue appends an array of boolean values to the current key in the JSON object.
+// Complexes64Value appends an array of complex64 values to the current key in the JSON object.
 //
 // Example:
 //
-//	obj.Key("flags").BoolsValue([]bool{true, false, true})
-func (o *Object) BoolsValue(value []bool) *Object {
-	o.buf = appendArray(o.buf, value, strconv.AppendBool)
-	o.buf = append(o.buf, ',')
-	return o
+//	obj.Key("samples").Complexes64Value([]complex64{1+2i, 3-4i})
+func (o *Object) Complexes64Value(value []complex64) *Object {
+	size := func(value complex64) int { return complexLen(complex128(value)) }
+	return appendArrayValue(o, value, size, func(buf []byte, value complex64) []byte {
+		return appendComplex(buf, complex128(value), 32, o.complexFormat)
+	})
 }
 
-// Time appends a time.Time key-value pair to the JSON object.
-// The time is formatted as a string according to the specified format.
+// Complex128 appends a complex128 key-value pair to the JSON object.
+// This is the base method that other complex methods call internally.
 //
 // Example:
 //
-//	obj.Time("created", time.Now(), time.RFC3339)
+//	obj.Complex128("z", 1+2i) // Encodes as "z":{"re":1,"im":2}
 //
-// The time will be encoded as a JSON string value with proper quotation marks.
-// Common formats include time.RFC3339, time.RFC822, and time.RFC1123.
-func (o *Object) Time(key string, value time.Time, format string) *Object {
-	return o.Key(key).TimeValue(value, format)
+// Use EncodeComplexAs to choose between the object, array and string representations.
+//
+// Note: Special values like NaN and Infinity in either part follow the same rules as Float64.
+func (o *Object) Complex128(key string, value complex128) *Object {
+	return o.Key(key).Complex128Value(value)
 }
 
-// TimeValue appends a time.Time value to the current key in the JSON object.
-// The time is formatted as a string according to the specified format.
+// Complex128Value appends a complex128 value to the current key in the JSON object.
 //
 // Example:
 //
-//	obj.Key("created").TimeValue(time.Now(), time.RFC3339)
-//
-// The time will be encoded as a JSON string value with proper quotation marks.
-// Common formats include time.RFC3339, time.RFC822, and time.RFC1123.
-func (o *Object) TimeValue(value time.Time, format string) *Object {
-	o.buf = appendTime(o.buf, value, format)
+//	obj.Key("z").Complex128Value(1+2i)
+func (o *Object) Complex128Value(value complex128) *Object {
+	if o.fixed && !o.reserve(complexLen(value)+1) {
+		return o
+	}
+	o.buf = appendComplex(o.buf, value, 64, o.complexFormat)
 	o.buf = append(o.buf, ',')
 	return o
 }
 
-// Times appends an array of time.Time values as a key-value pair to the JSON object.
-// All times are formatted as strings according to the specified format.
+// Complexes128 appends an array of complex128 values as a key-value pair to the JSON object.
 //
 // Example:
 //
-//	obj.Times("timestamps", []time.Time{time.Now(), time.Now().Add(-24*time.Hour)}, time.RFC3339)
-//
-// Each time will be encoded as a JSON string value with proper quotation marks.
-func (o *Object) Times(key string, value []time.Time, format string) *Object {
-	return o.Key(key).TimesValue(value, format)
+//	obj.Complexes128("samples", []complex128{1+2i, 3-4i})
+func (o *Object) Complexes128(key string, value []complex128) *Object {
+	return o.Key(key).Complexes128Value(value)
 }
 
-// TimesValue appends an array of time.Time values to the current key in the JSON object.
-// All times are formatted as strings according to the specified format.
+// Complexes128Value appends an array of complex128 values to the current key in the JSON object.
 //
 // Example:
 //
-//	obj.Key("timestamps").TimesValue([]time.Time{time.Now(), time.Now().Add(-24*time.Hour)}, time.RFC3339)
-//
-// Each time will be encoded as a JSON string value with proper quotation marks.
-func (o *Object) TimesValue(value []time.Time, format string) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, value time.Time) []byte {
-		return appendTime(buf, value, format)
+//	obj.Key("samples").Complexes128Value([]complex128{1+2i, 3-4i})
+func (o *Object) Complexes128Value(value []complex128) *Object {
+	return appendArrayValue(o, value, complexLen, func(buf []byte, value complex128) []byte {
+		return appendComplex(buf, value, 64, o.complexFormat)
 	})
-	o.buf = append(o.buf, ',')
-	return o
 }
 
-// Duration appends a time.Duration key-value pair to the JSON object.
-//
-// IMPORTANT: Unlike other numeric types, durations are encoded as strings using
-// the Duration.String() representation (e.g., "1h2m3s"), not as numeric nanoseconds.
-// This provides better human readability but may require specific parsing on the receiving end.
-//
-// If you want to represent a duration as a numeric value you can use the Int64(key, value) function
-// as a time.Duration is just an alias for int64.
+// DecimalFormat configures how the Decimal methods render fixed-point decimals, see EncodeDecimalsAs.
+type DecimalFormat struct {
+	// MinFractionDigits is the minimum number of digits written after the decimal point.
+	// Trailing zeros beyond this minimum are left out, so with the default of 0 an amount of
+	// 12300 with scale 2 is written as 123.
+	MinFractionDigits int
+	// String writes decimals as JSON strings instead of JSON numbers, for consumers that would
+	// otherwise parse them into a float.
+	String bool
+}
+
+// EncodeDecimalsAs sets the representation used by the Decimal methods and their Value and slice variants.
 //
 // Example:
 //
-//	obj.Duration("timeout", 5*time.Minute) // Encodes as "timeout":"5m0s"
-func (o *Object) Duration(key string, value time.Duration) *Object {
-	return o.Key(key).DurationValue(value)
+//	obj.EncodeDecimalsAs(fson.DecimalFormat{MinFractionDigits: 2}).Decimal("amount", 12300, 2)
+//	// Results in: {"amount":123.00}
+//
+// The setting is kept across calls to Reset().
+func (o *Object) EncodeDecimalsAs(format DecimalFormat) *Object {
+	o.decimalFormat = format
+	return o
 }
 
-// DurationValue appends a time.Duration value to the current key in the JSON object.
-//
-// IMPORTANT: Unlike other numeric types, durations are encoded as strings using
-// the Duration.String() representation (e.g., "1h2m3s"), not as numeric nanoseconds.
-// This provides better human readability but may require specific parsing on the receiving end.
-//
-// If you want to represent a duration as a numeric value you can use the Int64Value(value) function
-// as a time.Duration is just an alias for int64.
+// MaxDecimalScale is the largest scale, positive or negative, the Decimal methods accept. It leaves
+// room for all 19 digits of an int64 on either side of the decimal point, and then some.
+const MaxDecimalScale = 40
+
+// ErrDecimalScale is recorded when a Decimal method is called with a scale beyond ±MaxDecimalScale.
+var ErrDecimalScale = errors.New("fson: decimal scale out of range")
+
+// Decimal appends a fixed-point decimal key-value pair to the JSON object.
+// The value is unscaled * 10^-scale, so an amount of 12345 minor units with scale 2 is written as 123.45.
 //
 // Example:
 //
-//	obj.Key("timeout").DurationValue(5*time.Minute) // Encodes as "timeout":"5m0s"
-func (o *Object) DurationValue(value time.Duration) *Object {
-	return o.StringValue(value.String())
+//	obj.Decimal("amount", 12345, 2) // Encodes as "amount":123.45
+//
+// The digits are written exactly as they are, without going through a float64, so no precision is lost.
+// A negative scale appends zeros: Decimal("amount", 123, -2) encodes as 12300. A scale beyond
+// ±MaxDecimalScale is rejected: null is written instead and ErrDecimalScale is recorded, see Err().
+// Use EncodeDecimalsAs to control the number of fraction digits or to write the decimal as a string.
+func (o *Object) Decimal(key string, unscaled int64, scale int) *Object {
+	return o.Key(key).DecimalValue(unscaled, scale)
 }
 
-// Durations appends an array of time.Duration values as a key-value pair to the JSON object.
-//
-// IMPORTANT: Unlike other numeric types, durations are encoded as strings using
-// the Duration.String() representation (e.g., "1h2m3s"), not as numeric nanoseconds.
-// This provides better human readability but may require specific parsing on the receiving end.
+// DecimalValue appends a fixed-point decimal value to the current key in the JSON object.
 //
 // Example:
 //
-//	obj.Durations("intervals", []time.Duration{5*time.Second, 10*time.Minute})
-//	// Encodes as "intervals":["5s","10m0s"]
-func (o *Object) Durations(key string, value []time.Duration) *Object {
-	return o.Key(key).DurationsValue(value)
+//	obj.Key("amount").DecimalValue(12345, 2)
+func (o *Object) DecimalValue(unscaled int64, scale int) *Object {
+	if scale < -MaxDecimalScale || scale > MaxDecimalScale {
+		o.setErr(ErrDecimalScale)
+		return o.NullValue()
+	}
+	if o.fixed && !o.reserve(o.decimalLen(scale)+1) {
+		return o
+	}
+	o.buf = appendDecimal(o.buf, unscaled, scale, o.decimalFormat)
+	o.buf = append(o.buf, ',')
+	return o
 }
 
-// DurationsValue appends an array of time.Duration values to the current key in the JSON object.
+// Decimals appends an array of fixed-point decimals that share the same scale as a key-value pair
+// to the JSON object.
 //
-// IMPORTANT: Unlike other numeric types, durations are encoded as strings using
-// the Duration.String() representation (e.g., "1h2m3s"), not as numeric nanoseconds.
-// This provides better human readability but may require specific parsing on the receiving end.
+// Example:
+//
+//	obj.Decimals("amounts", []int64{12345, -50}, 2) // Encodes as "amounts":[123.45,-0.5]
+func (o *Object) Decimals(key string, unscaled []int64, scale int) *Object {
+	return o.Key(key).DecimalsValue(unscaled, scale)
+}
+
+// DecimalsValue appends an array of fixed-point decimals that share the same scale to the current key
+// in the JSON object.
 //
 // Example:
 //
-//	obj.Key("intervals").DurationsValue([]time.Duration{5*time.Second, 10*time.Minute})
-//	// Encodes as "intervals":["5s","10m0s"]
-func (o *Object) DurationsValue(value []time.Duration) *Object {
-	o.buf = appendArray(o.buf, value, func(buf []byte, v time.Duration) []byte {
-		return appendString(buf, v.String())
+//	obj.Key("amounts").DecimalsValue([]int64{12345, -50}, 2)
+func (o *Object) DecimalsValue(unscaled []int64, scale int) *Object {
+	if scale < -MaxDecimalScale || scale > MaxDecimalScale {
+		o.setErr(ErrDecimalScale)
+		return o.NullValue()
+	}
+	size := func(int64) int { return o.decimalLen(scale) }
+	return appendArrayValue(o, unscaled, size, func(buf []byte, value int64) []byte {
+		return appendDecimal(buf, value, scale, o.decimalFormat)
 	})
+}
+
+// Bool appends a boolean key-value pair to the JSON object.
+//
+// Example:
+//
+//	obj.Bool("active", true)
+func (o *Object) Bool(key string, value bool) *Object {
+	return o.Key(key).BoolValue(value)
+}
+
+// BoolValue appends a boolean value to the current key in the JSON object.
+//
+// Example:
+//
+//	obj.Key("active").BoolValue(true)
+func (o *Object) BoolValue(value bool) *Object {
+	if o.fixed && !o.reserve(len("false,")) {
+		return o
+	}
+	o.buf = strconv.AppendBool(o.buf, value)
 	o.buf = append(o.buf, ',')
 	return o
 }
 
-// Object adds a new nested object with the given key.
-// This is a convenience method that combines Key() and StartObject().
+// Bools appends an array of boolean values as a key-value pair to the JSON object.
 //
 // Example:
 //
-//	obj.Object("person").
-//	    String("name", "John").
-//	    Int("age", 30).
-//	EndObject()
+//	obj.Bools("flags", []bool{true, false, true})
+func (o *Object) Bools(key string, value []bool) *Object {
+	return o.Key(key).BoolsValue(value)
+}
+
+// BoolsValue appends an array of boolean values to the current key in the JSON object.
 //
-// Don't forget to call EndObject() when you're done adding properties to the nested object.
-func (o *Object) Object(key string) *Object {
-	return o.Key(key).StartObject()
+// Example:
+//
+//	obj.Key("flags").BoolsValue([]bool{true, false, true})
+func (o *Object) BoolsValue(value []bool) *Object {
+	return appendArrayValue(o, value, func(bool) int { return len("false") }, strconv.AppendBool)
 }
 
-// StartObject begins a new JSON object without a key.
-// This is typically used after Array() or StartArray() when adding objects to an array.
+// Time appends a time.Time key-value pair to the JSON object.
+// The time is formatted as a string according to the specified format.
 //
 // Example:
 //
-//	obj.Array("people").
-//	    StartObject().
-//	        String("name", "Alice").
-//	    EndObject().
-//	    StartObject().
-//	        String("name", "Bob").
-//	    EndObject().
-//	EndArray()
+//	obj.Time("created", time.Now(), time.RFC3339)
 //
-// Don't forget to call EndObject() when you're done adding properties to the object.
-func (o *Object) StartObject() *Object {
-	o.buf = append(o.buf, '{')
-	return o
+// The time will be encoded as a JSON string value with proper quotation marks.
+// Common formats include time.RFC3339, time.RFC822, and time.RFC1123.
+func (o *Object) Time(key string, value time.Time, format string) *Object {
+	return o.Key(key).TimeValue(value, format)
 }
 
-// EndObject completes the current object by adding a closing brace.
-// It should be called after Object()/StartObject() to close the nested object.
+// TimeValue appends a time.Time value to the current key in the JSON object.
+// The time is formatted as a string according to the specified format.
 //
-// If the object is empty it will output "{}".
+// Example:
 //
-// IMPORTANT: Each call to Object()/StartObject() must be paired with a call to EndObject().
-// Unbalanced calls may result in invalid JSON.
-func (o *Object) EndObject() *Object {
-	// If the object is empty just append the closing tag
-	// else replace the final comma with the closing tag
-	if o.buf[len(o.buf)-1] == '{' {
-		o.buf = append(o.buf, '}')
-	} else {
-		o.buf[len(o.buf)-1] = '}'
+//	obj.Key("created").TimeValue(time.Now(), time.RFC3339)
+//
+// The time will be encoded as a JSON string value with proper quotation marks.
+// Common formats include time.RFC3339, time.RFC822, and time.RFC1123.
+func (o *Object) TimeValue(value time.Time, format string) *Object {
+	if o.fixed && !o.reserve(timeLen(value, format)+1) {
+		return o
 	}
+	o.buf = appendTime(o.buf, value, format)
+	o.buf = append(o.buf, ',')
+	return o
+}
+
+// Times appends an array of time.Time values as a key-value pair to the JSON object.
+// All times are formatted as strings according to the specified format.
+//
+// Example:
+//
+//	obj.Times("timestamps", []time.Time{time.Now(), time.Now().Add(-24*time.Hour)}, time.RFC3339)
+//
+// Each time will be encoded as a JSON string value with proper quotation marks.
+func (o *Object) Times(key string, value []time.Time, format string) *Object {
+	return o.Key(key).TimesValue(value, format)
+}
+
+// TimesValue appends an array of time.Time values to the current key in the JSON object.
+// All times are formatted as strings according to the specified format.
+//
+// Example:
+//
+//	obj.Key("timestamps").TimesValue([]time.Time{time.Now(), time.Now().Add(-24*time.Hour)}, time.RFC3339)
+//
+// Each time will be encoded as a JSON string value with proper quotation marks.
+func (o *Object) TimesValue(value []time.Time, format string) *Object {
+	size := func(value time.Time) int { return timeLen(value, format) }
+	return appendArrayValue(o, value, size, func(buf []byte, value time.Time) []byte {
+		return appendTime(buf, value, format)
+	})
+}
+
+// NormalizeUTC controls whether the RFC 3339 time encoders (TimeRFC3339, TimeRFC3339Nano and
+// their Value and slice variants) convert times to UTC before formatting them.
+//
+// Example:
+//
+//	obj.NormalizeUTC(true).TimeRFC3339("created", time.Now())
+//	// Results in: {"created":"2025-04-01T12:00:00Z"}
+//
+// The setting is kept across calls to Reset(). It does not affect Time() and TimeValue(),
+// which always format the time as-is.
+func (o *Object) NormalizeUTC(enabled bool) *Object {
+	o.utc = enabled
+	return o
+}
+
+// TimeRFC3339 appends a time.Time key-value pair formatted as time.RFC3339 to the JSON object.
+//
+// Example:
+//
+//	obj.TimeRFC3339("created", time.Now())
+//
+// Apart from honoring NormalizeUTC, this produces the same output as Time(key, value, time.RFC3339),
+// but it uses a dedicated formatter that takes about half the time of time.Time.AppendFormat.
+func (o *Object) TimeRFC3339(key string, value time.Time) *Object {
+	return o.Key(key).TimeRFC3339Value(value)
+}
 
+// TimeRFC3339Value appends a time.Time value formatted as time.RFC3339 to the current key in the JSON object.
+//
+// Example:
+//
+//	obj.Key("created").TimeRFC3339Value(time.Now())
+func (o *Object) TimeRFC3339Value(value time.Time) *Object {
+	if o.fixed && !o.reserve(timeLen(o.normalizeTime(value), time.RFC3339)+1) {
+		return o
+	}
+	o.buf = appendTimeRFC3339(o.buf, o.normalizeTime(value), false)
 	o.buf = append(o.buf, ',')
 	return o
 }
 
-// Array adds a new array with the given key.
-// This is a convenience method that combines Key() and StartArray().
+// TimesRFC3339 appends an array of time.Time values formatted as time.RFC3339 as a key-value pair
+// to the JSON object.
 //
 // Example:
 //
-//	obj.Array("numbers").
-//	    StartObject().Int("value", 1).EndObject().
-//	    StartObject().Int("value", 2).EndObject().
-//	EndArray()
+//	obj.TimesRFC3339("timestamps", []time.Time{time.Now(), time.Now().Add(-24*time.Hour)})
+func (o *Object) TimesRFC3339(key string, value []time.Time) *Object {
+	return o.Key(key).TimesRFC3339Value(value)
+}
+
+// TimesRFC3339Value appends an array of time.Time values formatted as time.RFC3339 to the current key
+// in the JSON object.
 //
-// The difference between this and methods like Ints() is that Array allows
-// you to create arrays of heterogeneous or complex objects, while type-specific
-// methods like Ints() are for arrays where all elements are of the same type.
+// Example:
 //
-// Don't forget to call EndArray() when you're done adding items to the array.
-func (o *Object) Array(key string) *Object {
-	return o.Key(key).StartArray()
+//	obj.Key("timestamps").TimesRFC3339Value([]time.Time{time.Now(), time.Now().Add(-24*time.Hour)})
+func (o *Object) TimesRFC3339Value(value []time.Time) *Object {
+	size := func(value time.Time) int { return timeLen(o.normalizeTime(value), time.RFC3339) }
+	return appendArrayValue(o, value, size, func(buf []byte, value time.Time) []byte {
+		return appendTimeRFC3339(buf, o.normalizeTime(value), false)
+	})
 }
 
-// StartArray begins a new JSON array without a key.
-// This is typically used for creating nested array structures.
+// TimeRFC3339Nano appends a time.Time key-value pair formatted as time.RFC3339Nano to the JSON object.
 //
 // Example:
 //
-//	obj.Array("matrix").
-//	    StartArray().Int(1).Int(2).EndArray().
-//	    StartArray().Int(3).Int(4).EndArray().
-//	EndArray()
+//	obj.TimeRFC3339Nano("created", time.Now())
 //
-// Don't forget to call EndArray() when you're done adding items to the array.
-func (o *Object) StartArray() *Object {
-	o.buf = append(o.buf, '[')
+// Apart from honoring NormalizeUTC, this produces the same output as Time(key, value, time.RFC3339Nano),
+// but it uses a dedicated formatter that takes about half the time of time.Time.AppendFormat.
+// Trailing zeros of the fractional seconds are removed, just like time.RFC3339Nano does.
+func (o *Object) TimeRFC3339Nano(key string, value time.Time) *Object {
+	return o.Key(key).TimeRFC3339NanoValue(value)
+}
+
+// TimeRFC3339NanoValue appends a time.Time value formatted as time.RFC3339Nano to the current key
+// in the JSON object.
+//
+// Example:
+//
+//	obj.Key("created").TimeRFC3339NanoValue(time.Now())
+func (o *Object) TimeRFC3339NanoValue(value time.Time) *Object {
+	if o.fixed && !o.reserve(timeLen(o.normalizeTime(value), time.RFC3339Nano)+1) {
+		return o
+	}
+	o.buf = appendTimeRFC3339(o.buf, o.normalizeTime(value), true)
+	o.buf = append(o.buf, ',')
 	return o
 }
 
-// EndArray completes the current array by adding a closing bracket.
-// It should be called after Array()/StartArray() to close the array.
+// TimesRFC3339Nano appends an array of time.Time values formatted as time.RFC3339Nano as a key-value
+// pair to the JSON object.
+//
+// Example:
+//
+//	obj.TimesRFC3339Nano("timestamps", []time.Time{time.Now(), time.Now().Add(-24*time.Hour)})
+func (o *Object) TimesRFC3339Nano(key string, value []time.Time) *Object {
+	return o.Key(key).TimesRFC3339NanoValue(value)
+}
+
+// TimesRFC3339NanoValue appends an array of time.Time values formatted as time.RFC3339Nano to the
+// current key in the JSON object.
+//
+// Example:
+//
+//	obj.Key("timestamps").TimesRFC3339NanoValue([]time.Time{time.Now(), time.Now().Add(-24*time.Hour)})
+func (o *Object) TimesRFC3339NanoValue(value []time.Time) *Object {
+	size := func(value time.Time) int { return timeLen(o.normalizeTime(value), time.RFC3339Nano) }
+	return appendArrayValue(o, value, size, func(buf []byte, value time.Time) []byte {
+		return appendTimeRFC3339(buf, o.normalizeTime(value), true)
+	})
+}
+
+// normalizeTime converts t to UTC if the Object was configured to do so with NormalizeUTC.
+func (o *Object) normalizeTime(t time.Time) time.Time {
+	if o.utc {
+		return t.UTC()
+	}
+	return t
+}
+
+// Duration appends a time.Duration key-value pair to the JSON object.
+//
+// IMPORTANT: Unlike other numeric types, durations are encoded as strings using
+// the Duration.String() representation (e.g., "1h2m3s"), not as numeric nanoseconds.
+// This provides better human readability but may require specific parsing on the receiving end.
+//
+// If you want to represent a duration as a numeric value you can use the Int64(key, value) function
+// as a time.Duration is just an alias for int64.
+//
+// Example:
+//
+//	obj.Duration("timeout", 5*time.Minute) // Encodes as "timeout":"5m0s"
+func (o *Object) Duration(key string, value time.Duration) *Object {
+	return o.Key(key).DurationValue(value)
+}
+
+// DurationValue appends a time.Duration value to the current key in the JSON object.
+//
+// IMPORTANT: Unlike other numeric types, durations are encoded as strings using
+// the Duration.String() representation (e.g., "1h2m3s"), not as numeric nanoseconds.
+// This provides better human readability but may require specific parsing on the receiving end.
+//
+// If you want to represent a duration as a numeric value you can use the Int64Value(value) function
+// as a time.Duration is just an alias for int64.
+//
+// Example:
+//
+//	obj.Key("timeout").DurationValue(5*time.Minute) // Encodes as "timeout":"5m0s"
+func (o *Object) DurationValue(value time.Duration) *Object {
+	return o.StringValue(value.String())
+}
+
+// Durations appends an array of time.Duration values as a key-value pair to the JSON object.
+//
+// IMPORTANT: Unlike other numeric types, durations are encoded as strings using
+// the Duration.String() representation (e.g., "1h2m3s"), not as numeric nanoseconds.
+// This provides better human readability but may require specific parsing on the receiving end.
+//
+// Example:
+//
+//	obj.Durations("intervals", []time.Duration{5*time.Second, 10*time.Minute})
+//	// Encodes as "intervals":["5s","10m0s"]
+func (o *Object) Durations(key string, value []time.Duration) *Object {
+	return o.Key(key).DurationsValue(value)
+}
+
+// DurationsValue appends an array of time.Duration values to the current key in the JSON object.
+//
+// IMPORTANT: Unlike other numeric types, durations are encoded as strings using
+// the Duration.String() representation (e.g., "1h2m3s"), not as numeric nanoseconds.
+// This provides better human readability but may require specific parsing on the receiving end.
+//
+// Example:
+//
+//	obj.Key("intervals").DurationsValue([]time.Duration{5*time.Second, 10*time.Minute})
+//	// Encodes as "intervals":["5s","10m0s"]
+func (o *Object) DurationsValue(value []time.Duration) *Object {
+	size := func(time.Duration) int { return maxDurationLen }
+	return appendArrayValue(o, value, size, func(buf []byte, v time.Duration) []byte {
+		return appendString(buf, v.String())
+	})
+}
+
+// Stringer appends the result of value.String() as a string key-value pair to the JSON object.
+// A nil value is encoded as null.
+//
+// Example:
+//
+//	obj.Stringer("level", slog.LevelInfo) // Encodes as "level":"INFO"
+func (o *Object) Stringer(key string, value fmt.Stringer) *Object {
+	return o.Key(key).StringerValue(value)
+}
+
+// StringerValue appends the result of value.String() as a string value to the current key in the JSON object.
+// A nil value is encoded as null.
+//
+// Example:
+//
+//	obj.Key("level").StringerValue(slog.LevelInfo)
+//
+// If String panics, typically because value is a nil pointer, the value is encoded as null and the
+// panic is recorded as an error, see Err().
+func (o *Object) StringerValue(value fmt.Stringer) *Object {
+	if value == nil {
+		return o.NullValue()
+	}
+	s, err := stringOf(value)
+	if err != nil {
+		o.setErr(err)
+		return o.NullValue()
+	}
+	return o.StringValue(s)
+}
+
+// Text appends the text representation of value as a string key-value pair to the JSON object.
+// A nil value is encoded as null.
+//
+// Example:
+//
+//	obj.Text("ip", netip.MustParseAddr("192.168.0.1")) // Encodes as "ip":"192.168.0.1"
+//
+// If value also implements encoding.TextAppender the text is appended straight into the
+// underlying buffer, avoiding the intermediate byte slice returned by MarshalText.
+//
+// If marshalling fails the value is encoded as null and the error is recorded, see Err().
+func (o *Object) Text(key string, value encoding.TextMarshaler) *Object {
+	return o.Key(key).TextValue(value)
+}
+
+// TextValue appends the text representation of value as a string value to the current key in the JSON object.
+// A nil value is encoded as null.
+//
+// Example:
+//
+//	obj.Key("ip").TextValue(netip.MustParseAddr("192.168.0.1"))
+//
+// If marshalling fails, or panics because value is a nil pointer, the value is encoded as null and
+// the error is recorded, see Err().
+func (o *Object) TextValue(value encoding.TextMarshaler) *Object {
+	if value == nil {
+		return o.NullValue()
+	}
+	if err := o.appendTextValue(value); err != nil {
+		o.setErr(err)
+		return o.NullValue()
+	}
+	return o
+}
+
+// appendTextValue appends the text representation of value as a string value. The buffer is left
+// untouched if this fails.
+func (o *Object) appendTextValue(value encoding.TextMarshaler) (err error) {
+	defer recoverPanic(&err, "MarshalText")
+
+	start := len(o.buf)
+	if appender, ok := value.(encoding.TextAppender); ok {
+		if o.fixed && !o.reserve(1) {
+			return nil
+		}
+		// AppendText may return a nil slice together with an error, so only replace the buffer on success.
+		buf, err := appender.AppendText(append(o.buf, '"'))
+		if err != nil {
+			return err
+		}
+		if o.fixed && cap(buf) != cap(o.buf) {
+			o.overflow() // AppendText outgrew the buffer
+			return nil
+		}
+		o.buf = buf
+	} else {
+		text, err := value.MarshalText()
+		if err != nil {
+			return err
+		}
+		if o.fixed && !o.reserve(len(text)+1) {
+			return nil
+		}
+		o.buf = append(o.buf, '"')
+		o.buf = append(o.buf, text...)
+	}
+
+	o.closeStringValue(start, 0)
+	return nil
+}
+
+// Error appends the message of err as a string key-value pair to the JSON object.
+// A nil error is encoded as null.
+//
+// Example:
+//
+//	obj.Error("error", io.EOF) // Encodes as "error":"EOF"
+func (o *Object) Error(key string, err error) *Object {
+	return o.Key(key).ErrorValue(err)
+}
+
+// ErrorValue appends the message of err as a string value to the current key in the JSON object.
+// A nil error is encoded as null.
+//
+// Example:
+//
+//	obj.Key("error").ErrorValue(io.EOF)
+func (o *Object) ErrorValue(err error) *Object {
+	if err == nil {
+		return o.NullValue()
+	}
+	msg, perr := errorOf(err)
+	if perr != nil {
+		o.setErr(perr)
+		return o.NullValue()
+	}
+	return o.StringValue(msg)
+}
+
+// ErrorDetail appends err as a structured JSON object with the given key.
+// A nil error is encoded as null.
+//
+// Example:
+//
+//	err := fmt.Errorf("loading config: %w", errors.Join(os.ErrNotExist, os.ErrPermission))
+//	obj.ErrorDetail("error", err)
+//	// Results in: {"error":{"message":"loading config: file does not exist\npermission denied",
+//	//   "causes":[{"message":"file does not exist\npermission denied",
+//	//     "causes":[{"message":"file does not exist"},{"message":"permission denied"}]}]}}
+//
+// The object always contains the "message" of the error. Errors that implement ObjectMarshaler
+// can add their own members right after it. The chain of wrapped errors is followed through
+// Unwrap() error and Unwrap() []error (as returned by errors.Join) and written to the "causes"
+// array, which is left out if the error does not wrap anything.
+//
+// To protect against cyclic or absurdly large error trees only MaxErrorDepth levels of causes, and
+// at most MaxErrorCauses errors in total, are written. An error whose causes are left out, or only
+// partially written, gets a "truncated":true member.
+func (o *Object) ErrorDetail(key string, err error) *Object {
+	return o.Key(key).ErrorDetailValue(err)
+}
+
+// ErrorDetailValue appends err as a structured JSON object to the current key in the JSON object.
+// A nil error is encoded as null.
+//
+// Example:
+//
+//	obj.Key("error").ErrorDetailValue(err)
+//
+// See ErrorDetail for a description of the produced object.
+func (o *Object) ErrorDetailValue(err error) *Object {
+	if err == nil {
+		return o.NullValue()
+	}
+	o.appendErrorDetail(err, 0, MaxErrorCauses)
+	return o
+}
+
+// MaxErrorDepth is the maximum number of levels of wrapped errors written by ErrorDetail.
+const MaxErrorDepth = 16
+
+// MaxErrorCauses is the maximum number of errors, including the error itself, written by ErrorDetail.
+const MaxErrorCauses = 64
+
+// appendErrorDetail writes err and its causes as an object, depth is the level of err in the chain.
+// budget is the number of errors that may still be written, the remaining budget is returned.
+func (o *Object) appendErrorDetail(err error, depth, budget int) int {
+	budget--
+	msg, perr := errorOf(err)
+	if perr != nil {
+		o.setErr(perr)
+		o.NullValue()
+		return budget
+	}
+	o.StartObject().String("message", msg)
+
+	if m, ok := err.(ObjectMarshaler); ok {
+		if merr := m.MarshalFSON(o); merr != nil {
+			o.setErr(merr)
+		}
+	}
+
+	var causes []error
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if cause := u.Unwrap(); cause != nil {
+			causes = []error{cause}
+		}
+	case interface{ Unwrap() []error }:
+		causes = u.Unwrap()
+	}
+
+	if len(causes) == 0 {
+		o.EndObject()
+		return budget
+	}
+
+	if depth+1 >= MaxErrorDepth || budget <= 0 {
+		o.Bool("truncated", true).EndObject()
+		return budget
+	}
+
+	truncated := false
+	o.Array("causes")
+	for _, cause := range causes {
+		if cause == nil {
+			continue
+		}
+		if budget <= 0 {
+			truncated = true
+			break
+		}
+		budget = o.appendErrorDetail(cause, depth+1, budget)
+	}
+	o.EndArray()
+
+	if truncated {
+		o.Bool("truncated", true)
+	}
+	o.EndObject()
+	return budget
+}
+
+// Stack appends the call stack of the calling goroutine as an array of frame objects with the given key.
+//
+// Example:
+//
+//	obj.Stack("stack", 0)
+//	// Results in: {"stack":[{"function":"main.main","file":"/app/main.go","line":12},...]}
+//
+// The skip parameter is the number of stack frames to skip, with 0 identifying the caller of Stack.
+// At most MaxStackDepth frames are written, use Frames if you want to provide your own program counters.
+//
+// Unlike most methods Stack allocates: runtime.CallersFrames keeps a reference to the program counters,
+// so both they and the frame iterator end up on the heap, which costs two allocations per call. Stack is
+// meant for error paths, not for every event.
+func (o *Object) Stack(key string, skip int) *Object {
+	var pcs [MaxStackDepth]uintptr
+	// Skip runtime.Callers and Stack itself
+	n := runtime.Callers(skip+2, pcs[:])
+	return o.Key(key).FramesValue(pcs[:n])
+}
+
+// StackValue appends the call stack of the calling goroutine as an array of frame objects to the
+// current key in the JSON object.
+//
+// Example:
+//
+//	obj.Key("stack").StackValue(0)
+//
+// See Stack for the meaning of skip.
+func (o *Object) StackValue(skip int) *Object {
+	var pcs [MaxStackDepth]uintptr
+	// Skip runtime.Callers and StackValue itself
+	n := runtime.Callers(skip+2, pcs[:])
+	return o.FramesValue(pcs[:n])
+}
+
+// Frames appends the stack frames identified by the program counters in pcs as an array of frame
+// objects with the given key.
+//
+// Example:
+//
+//	pcs := make([]uintptr, 32)
+//	n := runtime.Callers(1, pcs)
+//	obj.Frames("stack", pcs[:n])
+//
+// The program counters are typically collected with runtime.Callers, for example when an error
+// is created, and resolved through runtime.CallersFrames only when they are encoded, which allocates
+// the frame iterator. Each frame is written as an object with a "function", "file" and "line" member.
+func (o *Object) Frames(key string, pcs []uintptr) *Object {
+	return o.Key(key).FramesValue(pcs)
+}
+
+// FramesValue appends the stack frames identified by the program counters in pcs as an array of frame
+// objects to the current key in the JSON object.
+//
+// Example:
+//
+//	obj.Key("stack").FramesValue(pcs[:n])
+func (o *Object) FramesValue(pcs []uintptr) *Object {
+	o.StartArray()
+	if len(pcs) > 0 {
+		frames := runtime.CallersFrames(pcs)
+		for {
+			frame, more := frames.Next()
+			o.StartObject().
+				String("function", frame.Function).
+				String("file", frame.File).
+				Int("line", frame.Line).
+				EndObject()
+			if !more {
+				break
+			}
+		}
+	}
+	return o.EndArray()
+}
+
+// MaxStackDepth is the maximum number of frames written by Stack.
+const MaxStackDepth = 64
+
+// Addr appends a netip.Addr as a string key-value pair to the JSON object.
+//
+// Example:
+//
+//	obj.Addr("ip", netip.MustParseAddr("2001:db8::1")) // Encodes as "ip":"2001:db8::1"
+//
+// The address is appended directly into the buffer without going through Addr.String().
+// The zero Addr is encoded as an empty string, just like its MarshalText method does.
+func (o *Object) Addr(key string, value netip.Addr) *Object {
+	return o.Key(key).AddrValue(value)
+}
+
+// AddrValue appends a netip.Addr as a string value to the current key in the JSON object.
+//
+// Example:
+//
+//	obj.Key("ip").AddrValue(netip.MustParseAddr("2001:db8::1"))
+func (o *Object) AddrValue(value netip.Addr) *Object {
+	if o.fixed && !o.reserve(maxAddrLen+len(value.Zone())) {
+		return o
+	}
+	start := len(o.buf)
+	o.buf = append(o.buf, '"')
+	o.buf, _ = value.AppendText(o.buf) // never fails
+	o.closeStringValue(start, 0)
+	return o
+}
+
+// AddrPort appends a netip.AddrPort as a string key-value pair to the JSON object.
+//
+// Example:
+//
+//	obj.AddrPort("remote", netip.MustParseAddrPort("[::1]:8080")) // Encodes as "remote":"[::1]:8080"
+//
+// The zero AddrPort is encoded as an empty string, just like its MarshalText method does.
+func (o *Object) AddrPort(key string, value netip.AddrPort) *Object {
+	return o.Key(key).AddrPortValue(value)
+}
+
+// AddrPortValue appends a netip.AddrPort as a string value to the current key in the JSON object.
+//
+// Example:
+//
+//	obj.Key("remote").AddrPortValue(netip.MustParseAddrPort("[::1]:8080"))
+func (o *Object) AddrPortValue(value netip.AddrPort) *Object {
+	if o.fixed && !o.reserve(maxAddrLen+len(value.Addr().Zone())) {
+		return o
+	}
+	start := len(o.buf)
+	o.buf = append(o.buf, '"')
+	o.buf, _ = value.AppendText(o.buf) // never fails
+	o.closeStringValue(start, 0)
+	return o
+}
+
+// Prefix appends a netip.Prefix as a string key-value pair to the JSON object.
+//
+// Example:
+//
+//	obj.Prefix("subnet", netip.MustParsePrefix("10.0.0.0/8")) // Encodes as "subnet":"10.0.0.0/8"
+//
+// The zero Prefix is encoded as an empty string, just like its MarshalText method does.
+func (o *Object) Prefix(key string, value netip.Prefix) *Object {
+	return o.Key(key).PrefixValue(value)
+}
+
+// PrefixValue appends a netip.Prefix as a string value to the current key in the JSON object.
+//
+// Example:
+//
+//	obj.Key("subnet").PrefixValue(netip.MustParsePrefix("10.0.0.0/8"))
+func (o *Object) PrefixValue(value netip.Prefix) *Object {
+	if o.fixed && !o.reserve(maxAddrLen) {
+		return o
+	}
+	start := len(o.buf)
+	o.buf = append(o.buf, '"')
+	o.buf, _ = value.AppendText(o.buf) // never fails
+	o.closeStringValue(start, 0)
+	return o
+}
+
+// IP appends a net.IP as a string key-value pair to the JSON object.
+// A nil or empty IP is encoded as null.
+//
+// Example:
+//
+//	obj.IP("ip", net.IPv4(192, 168, 0, 1)) // Encodes as "ip":"192.168.0.1"
+//
+// IPv4-mapped IPv6 addresses are written in their IPv4 form, like net.IP.String() does.
+// An IP with an invalid length is encoded as null and the error is recorded, see Err().
+func (o *Object) IP(key string, value net.IP) *Object {
+	return o.Key(key).IPValue(value)
+}
+
+// IPValue appends a net.IP as a string value to the current key in the JSON object.
+// A nil or empty IP is encoded as null.
+//
+// Example:
+//
+//	obj.Key("ip").IPValue(net.IPv4(192, 168, 0, 1))
+func (o *Object) IPValue(value net.IP) *Object {
+	if len(value) == 0 {
+		return o.NullValue()
+	}
+
+	addr, ok := netip.AddrFromSlice(value)
+	if !ok {
+		o.setErr(&net.AddrError{Err: "invalid IP address", Addr: hex.EncodeToString(value)})
+		return o.NullValue()
+	}
+
+	return o.AddrValue(addr.Unmap())
+}
+
+// URL appends a *url.URL as a string key-value pair to the JSON object.
+// A nil URL is encoded as null.
+//
+// Example:
+//
+//	u, _ := url.Parse("https://example.com/search?q=fson")
+//	obj.URL("url", u) // Encodes as "url":"https://example.com/search?q=fson"
+func (o *Object) URL(key string, value *url.URL) *Object {
+	return o.Key(key).URLValue(value)
+}
+
+// URLValue appends a *url.URL as a string value to the current key in the JSON object.
+// A nil URL is encoded as null.
+//
+// Example:
+//
+//	obj.Key("url").URLValue(u)
+func (o *Object) URLValue(value *url.URL) *Object {
+	if value == nil {
+		return o.NullValue()
+	}
+
+	if o.fixed && !o.reserve(urlLen(value)) {
+		return o
+	}
+	start := len(o.buf)
+	o.buf = append(o.buf, '"')
+	o.buf, _ = value.AppendBinary(o.buf) // never fails
+	o.closeStringValue(start, 0)
+	return o
+}
+
+// UUID appends a 16 byte UUID as a string key-value pair to the JSON object.
+// The UUID is written in its canonical, lowercase 8-4-4-4-12 form.
+//
+// Example:
+//
+//	obj.UUID("id", id) // Encodes as "id":"6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+//
+// Most UUID packages define their UUID type as a [16]byte array, so such values can be passed directly.
+func (o *Object) UUID(key string, value [16]byte) *Object {
+	return o.Key(key).UUIDValue(value)
+}
+
+// UUIDValue appends a 16 byte UUID as a string value to the current key in the JSON object.
+// The UUID is written in its canonical, lowercase 8-4-4-4-12 form.
+//
+// Example:
+//
+//	obj.Key("id").UUIDValue(id)
+func (o *Object) UUIDValue(value [16]byte) *Object {
+	if o.fixed && !o.reserve(len(`"00000000-0000-0000-0000-000000000000",`)) {
+		return o
+	}
+	o.buf = appendUUID(o.buf, value)
+	o.buf = append(o.buf, ',')
+	return o
+}
+
+// Marshaler appends value as a nested object with the given key by calling its MarshalFSON method.
+// A nil value is encoded as null.
+//
+// Example:
+//
+//	obj.Marshaler("user", user)
+//	// Results in: {"user":{"name":"John","age":30}}
+//
+// An error returned from MarshalFSON is recorded, see Err(). The members written so far are kept.
+func (o *Object) Marshaler(key string, value ObjectMarshaler) *Object {
+	return o.Key(key).MarshalerValue(value)
+}
+
+// MarshalerValue appends value as an object to the current key in the JSON object by calling
+// its MarshalFSON method. A nil value is encoded as null, and so is a value whose MarshalFSON method
+// panics, for example because it is a nil pointer; the panic is recorded as an error, see Err().
+//
+// Example:
+//
+//	obj.Key("user").MarshalerValue(user)
+func (o *Object) MarshalerValue(value ObjectMarshaler) *Object {
+	if value == nil {
+		return o.NullValue()
+	}
+
+	// A panic, for example on a nil pointer, leaves the object half-written and its containers open
+	sp := o.Savepoint()
+	o.StartObject()
+	err, perr := marshalOf(value, o)
+	if perr != nil {
+		o.Rollback(sp).setErr(perr)
+		return o.NullValue()
+	}
+	if err != nil {
+		o.setErr(err)
+	}
+	return o.EndObject()
+}
+
+// Any appends a value of a dynamic type as a key-value pair to the JSON object.
+//
+// Example:
+//
+//	obj.Any("attrs", map[string]any{"retries": 3, "timeout": 5 * time.Second})
+//	// Results in: {"attrs":{"retries":3,"timeout":"5s"}}
+//
+// Any is meant for forwarding loosely-typed data, for example from configuration or log attributes.
+// It uses a type switch instead of reflection and supports:
+//
+//   - nil, which is encoded as null
+//   - all built-in boolean, string, integer, floating-point and complex types and slices of them
+//   - time.Time (encoded with TimeRFC3339NanoValue), time.Duration and slices of them
+//   - map[string]any and []any, whose elements are encoded with Any again
+//   - types implementing ObjectMarshaler, error, encoding.TextMarshaler or fmt.Stringer, in that order
+//
+// Values of any other type are handed to the fallback configured with AnyFallback.
+// Note that the members of a map[string]any are written in Go's random map iteration order.
+//
+// A []any or map[string]any that contains itself, or that is nested more than MaxAnyDepth levels
+// deep, is replaced by DefaultDepthPlaceholder and ErrMaxDepth is recorded, see Err().
+func (o *Object) Any(key string, value any) *Object {
+	return o.Key(key).AnyValue(value)
+}
+
+// AnyValue appends a value of a dynamic type to the current key in the JSON object.
+// See Any for the supported types.
+//
+// Example:
+//
+//	obj.Key("attrs").AnyValue(attrs)
+func (o *Object) AnyValue(value any) *Object {
+	return o.anyValue(value, nil, 0)
+}
+
+// MaxAnyDepth is the maximum number of levels of []any and map[string]any values written by Any.
+const MaxAnyDepth = 32
+
+// anyPath links the []any and map[string]any values that contain the value being written by Any,
+// identified by their backing storage, which makes it possible to detect cycles.
+type anyPath struct {
+	data   unsafe.Pointer
+	parent *anyPath
+}
+
+// enter returns the path extended with data, or false if data is already part of it or the path is
+// too long.
+func (p *anyPath) enter(data unsafe.Pointer, depth int) (anyPath, bool) {
+	if depth >= MaxAnyDepth {
+		return anyPath{}, false
+	}
+	for q := p; q != nil; q = q.parent {
+		if q.data == data {
+			return anyPath{}, false
+		}
+	}
+	return anyPath{data: data, parent: p}, true
+}
+
+// anyValue implements AnyValue, path holds the containers of value and depth their number.
+func (o *Object) anyValue(value any, path *anyPath, depth int) *Object { //nolint: cyclop, funlen
+	switch v := value.(type) {
+	case nil:
+		return o.NullValue()
+	case bool:
+		return o.BoolValue(v)
+	case string:
+		return o.StringValue(v)
+	case int:
+		return o.IntValue(v)
+	case int8:
+		return o.Int8Value(v)
+	case int16:
+		return o.Int16Value(v)
+	case int32:
+		return o.Int32Value(v)
+	case int64:
+		return o.Int64Value(v)
+	case uint:
+		return o.UintValue(v)
+	case uint8:
+		return o.Uint8Value(v)
+	case uint16:
+		return o.Uint16Value(v)
+	case uint32:
+		return o.Uint32Value(v)
+	case uint64:
+		return o.Uint64Value(v)
+	case uintptr:
+		return UintValue(o, v)
+	case float32:
+		return FloatValue(o, v)
+	case float64:
+		return o.Float64Value(v)
+	case complex64:
+		return o.Complex64Value(v)
+	case complex128:
+		return o.Complex128Value(v)
+	case time.Time:
+		return o.TimeRFC3339NanoValue(v)
+	case time.Duration:
+		return o.DurationValue(v)
+	case []bool:
+		return o.BoolsValue(v)
+	case []string:
+		return o.StringsValue(v)
+	case []int:
+		return o.IntsValue(v)
+	case []int8:
+		return o.Ints8Value(v)
+	case []int16:
+		return o.Ints16Value(v)
+	case []int32:
+		return o.Ints32Value(v)
+	case []int64:
+		return o.Ints64Value(v)
+	case []uint:
+		return o.UintsValue(v)
+	case []uint8:
+		return o.Uints8Value(v)
+	case []uint16:
+		return o.Uints16Value(v)
+	case []uint32:
+		return o.Uints32Value(v)
+	case []uint64:
+		return o.Uints64Value(v)
+	case []float32:
+		return o.Floats32Value(v)
+	case []float64:
+		return o.Floats64Value(v)
+	case []complex64:
+		return o.Complexes64Value(v)
+	case []complex128:
+		return o.Complexes128Value(v)
+	case []time.Time:
+		return o.TimesRFC3339NanoValue(v)
+	case []time.Duration:
+		return o.DurationsValue(v)
+	case []any:
+		inner, ok := path.enter(unsafe.Pointer(unsafe.SliceData(v)), depth)
+		if !ok && len(v) > 0 {
+			o.setErr(ErrMaxDepth)
+			return o.StringValue(DefaultDepthPlaceholder)
+		}
+		o.StartArray()
+		for _, elem := range v {
+			o.anyValue(elem, &inner, depth+1)
+		}
+		return o.EndArray()
+	case map[string]any:
+		// A map is a pointer to its header, which identifies it
+		inner, ok := path.enter(*(*unsafe.Pointer)(unsafe.Pointer(&v)), depth)
+		if !ok && len(v) > 0 {
+			o.setErr(ErrMaxDepth)
+			return o.StringValue(DefaultDepthPlaceholder)
+		}
+		o.StartObject()
+		for k, elem := range v {
+			o.Key(k).anyValue(elem, &inner, depth+1)
+		}
+		return o.EndObject()
+	case ObjectMarshaler:
+		return o.MarshalerValue(v)
+	case error:
+		return o.ErrorValue(v)
+	case encoding.TextMarshaler:
+		return o.TextValue(v)
+	case fmt.Stringer:
+		return o.StringerValue(v)
+	}
+
+	if o.anyFallback != nil {
+		o.anyFallback(o, value)
+	} else {
+		FallbackError(o, value)
+	}
+	return o
+}
+
+// AnyFallback sets the function Any and AnyValue use to encode values of an unsupported type.
+// The fallback must append exactly one value, for example with one of the Value methods.
+//
+// Example:
+//
+//	obj.AnyFallback(fson.FallbackSprint)
+//
+//	obj.AnyFallback(func(o *fson.Object, v any) {
+//	    if m, ok := v.(json.Marshaler); ok { ... }
+//	})
+//
+// By default, and when fallback is nil, FallbackError is used. The setting is kept across calls to Reset().
+func (o *Object) AnyFallback(fallback func(o *Object, value any)) *Object {
+	o.anyFallback = fallback
+	return o
+}
+
+// FallbackError is an Any fallback that encodes the value as null and records an UnsupportedTypeError,
+// see Err(). This is the default fallback.
+func FallbackError(o *Object, value any) {
+	o.setErr(&UnsupportedTypeError{Value: value})
+	o.NullValue()
+}
+
+// FallbackSprint is an Any fallback that encodes the value as a string formatted with fmt.Sprint.
+// Keep in mind that, unlike the rest of fson, fmt relies on reflection and allocates.
+func FallbackSprint(o *Object, value any) {
+	o.StringValue(fmt.Sprint(value))
+}
+
+// UnsupportedTypeError is recorded by FallbackError when Any is given a value of a type it does not support.
+type UnsupportedTypeError struct {
+	Value any
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("fson: unsupported type %T", e.Value)
+}
+
+// Object adds a new nested object with the given key.
+// This is a convenience method that combines Key() and StartObject().
+//
+// Example:
+//
+//	obj.Object("person").
+//	    String("name", "John").
+//	    Int("age", 30).
+//	EndObject()
+//
+// Don't forget to call EndObject() when you're done adding properties to the nested object.
+func (o *Object) Object(key string) *Object {
+	return o.Key(key).StartObject()
+}
+
+// StartObject begins a new JSON object without a key.
+// This is typically used after Array() or StartArray() when adding objects to an array.
+//
+// Example:
+//
+//	obj.Array("people").
+//	    StartObject().
+//	        String("name", "Alice").
+//	    EndObject().
+//	    StartObject().
+//	        String("name", "Bob").
+//	    EndObject().
+//	EndArray()
+//
+// Don't forget to call EndObject() when you're done adding properties to the object.
+func (o *Object) StartObject() *Object {
+	o.push(containerObject)
+	if !o.fixed || o.reserve(1) {
+		o.buf = append(o.buf, '{')
+	}
+	return o
+}
+
+// EndObject completes the current object by adding a closing brace.
+// It should be called after Object()/StartObject() to close the nested object.
+//
+// If the object is empty it will output "{}".
+//
+// IMPORTANT: Each call to Object()/StartObject() must be paired with a call to EndObject().
+// Unbalanced calls may result in invalid JSON.
+func (o *Object) EndObject() *Object {
+	if o.maxSize > 0 {
+		o.endMember()
+	}
+	if c := o.pop(); o.overflowed {
+		return o
+	} else if c.tooDeep {
+		return o.replaceTooDeep(c)
+	}
+	if o.fixed && !o.reserveClose('{') {
+		return o
+	}
+
+	// If the object is empty just append the closing tag
+	// else replace the final comma with the closing tag
+	if o.buf[len(o.buf)-1] == '{' {
+		o.buf = append(o.buf, '}')
+	} else {
+		o.buf[len(o.buf)-1] = '}'
+	}
+
+	o.buf = append(o.buf, ',')
+	return o
+}
+
+// ObjectString adds a new nested object with the given key that is encoded as a JSON string.
+// This is a convenience method that combines Key() and StartObjectString().
+//
+// Example:
+//
+//	obj.ObjectString("attributes").
+//	    String("type", "order").
+//	    Int("version", 2).
+//	EndObjectString()
+//	// Results in: {"attributes":"{\"type\":\"order\",\"version\":2}"}
+//
+// This is useful for APIs that expect a JSON document embedded as a string inside another JSON
+// document. The nested object is written straight into the buffer and escaped in place once
+// EndObjectString() is called, so there is no need to build it in a separate buffer first.
+//
+// Don't forget to call EndObjectString() when you're done adding properties to the nested object.
+func (o *Object) ObjectString(key string) *Object {
+	return o.Key(key).StartObjectString()
+}
+
+// StartObjectString begins a new JSON object without a key that is encoded as a JSON string.
+// This is typically used after Array() or StartArray() when adding embedded documents to an array.
+//
+// Example:
+//
+//	obj.Array("messages").
+//	    StartObjectString().String("id", "1").EndObjectString().
+//	EndArray()
+//	// Results in: {"messages":["{\"id\":\"1\"}"]}
+//
+// Within the embedded object the regular API, including nested objects, arrays and even
+// other embedded objects, can be used.
+func (o *Object) StartObjectString() *Object {
+	if !o.fixed || o.reserve(2) {
+		o.buf = append(o.buf, '"')
+	}
+	o.push(containerObjectString)
+	if !o.overflowed {
+		o.buf = append(o.buf, '{')
+	}
+	return o
+}
+
+// EndObjectString completes the current embedded object by adding a closing brace, escaping the
+// object and closing the string.
+//
+// IMPORTANT: Each call to ObjectString()/StartObjectString() must be paired with a call to
+// EndObjectString(). Calling it while the innermost open container is not an embedded object panics.
+func (o *Object) EndObjectString() *Object {
+	if c := o.top(); c == nil || c.kind != containerObjectString {
+		panic("fson: EndObjectString called without a matching StartObjectString")
+	}
+	if o.maxSize > 0 {
+		o.endMember()
+	}
+	c := o.pop()
+	if o.overflowed {
+		return o
+	} else if c.tooDeep {
+		return o.replaceTooDeep(c)
+	}
+	if o.fixed && !o.reserveClose('{') {
+		return o
+	}
+
+	if o.buf[len(o.buf)-1] == '{' {
+		o.buf = append(o.buf, '}')
+	} else {
+		o.buf[len(o.buf)-1] = '}'
+	}
+
+	// Cutting the embedded document short would leave invalid JSON behind, so it is replaced as a whole
+	if limit := o.maxStringSize; limit > 0 && len(o.buf)-c.start > limit {
+		cut := len(o.buf) - c.start
+		o.buf = o.buf[:c.start]
+		if o.fixed && !o.reserve(escapedLen(o.truncateSuffix)+maxIntLen+2) {
+			return o
+		}
+		o.buf = appendTruncateSuffix(o.buf, o.truncateSuffix, cut)
+		o.buf = append(o.buf, '"', ',')
+		return o
+	}
+
+	// The opening quote sits right before the brace
+	o.closeStringValue(c.start-1, 0)
+	return o
+}
+
+// Array adds a new array with the given key.
+// This is a convenience method that combines Key() and StartArray().
+//
+// Example:
+//
+//	obj.Array("numbers").
+//	    StartObject().Int("value", 1).EndObject().
+//	    StartObject().Int("value", 2).EndObject().
+//	EndArray()
+//
+// The difference between this and methods like Ints() is that Array allows
+// you to create arrays of heterogeneous or complex objects, while type-specific
+// methods like Ints() are for arrays where all elements are of the same type.
+//
+// Don't forget to call EndArray() when you're done adding items to the array.
+func (o *Object) Array(key string) *Object {
+	return o.Key(key).StartArray()
+}
+
+// StartArray begins a new JSON array without a key.
+// This is typically used for creating nested array structures.
+//
+// Example:
+//
+//	obj.Array("matrix").
+//	    StartArray().Int(1).Int(2).EndArray().
+//	    StartArray().Int(3).Int(4).EndArray().
+//	EndArray()
+//
+// Don't forget to call EndArray() when you're done adding items to the array.
+func (o *Object) StartArray() *Object {
+	o.push(containerArray)
+	if !o.fixed || o.reserve(1) {
+		o.buf = append(o.buf, '[')
+	}
+	return o
+}
+
+// EndArray completes the current array by adding a closing bracket.
+// It should be called after Array()/StartArray() to close the array.
+//
+// If the array is empty (no items added), it will output "[]".
+// Otherwise, it will replace the trailing comma with a closing bracket.
+//
+// IMPORTANT: Each call to Array()/StartArray() must be paired with a call to EndArray().
+// Unbalanced calls may result in invalid JSON.
+func (o *Object) EndArray() *Object {
+	if o.maxSize > 0 {
+		o.endMember()
+	}
+	if c := o.pop(); o.overflowed {
+		return o
+	} else if c.tooDeep {
+		return o.replaceTooDeep(c)
+	}
+	if o.fixed && !o.reserveClose('[') {
+		return o
+	}
+
+	// If the array is empty just append the closing array tag
+	// otherwise replace the final , with a closing array tag
+	if o.buf[len(o.buf)-1] == '[' {
+		o.buf = append(o.buf, ']')
+	} else {
+		o.buf[len(o.buf)-1] = ']'
+	}
+
+	o.buf = append(o.buf, ',')
+	return o
+}
+
+// ObjectFunc adds a new nested object with the given key whose members are added by fn.
+// The object is always closed after fn returns, so it cannot be left unbalanced.
+//
+// Example:
+//
+//	obj.ObjectFunc("person", func(o *fson.Object) {
+//	    o.String("name", "John").Int("age", 30)
+//	})
+//	// Results in: {"person":{"name":"John","age":30}}
+func (o *Object) ObjectFunc(key string, fn func(*Object)) *Object {
+	return o.Key(key).StartObjectFunc(fn)
+}
+
+// StartObjectFunc begins a new JSON object without a key whose members are added by fn.
+// The object is always closed after fn returns.
+//
+// Example:
+//
+//	obj.ArrayFunc("people", func(o *fson.Object) {
+//	    for _, p := range people {
+//	        o.StartObjectFunc(func(o *fson.Object) { o.String("name", p.Name) })
+//	    }
+//	})
+func (o *Object) StartObjectFunc(fn func(*Object)) *Object {
+	o.StartObject()
+	fn(o)
+	return o.EndObject()
+}
+
+// ArrayFunc adds a new array with the given key whose items are added by fn.
+// The array is always closed after fn returns, so it cannot be left unbalanced.
+//
+// Example:
+//
+//	obj.ArrayFunc("matrix", func(o *fson.Object) {
+//	    o.IntsValue([]int{1, 2}).IntsValue([]int{3, 4})
+//	})
+//	// Results in: {"matrix":[[1,2],[3,4]]}
+func (o *Object) ArrayFunc(key string, fn func(*Object)) *Object {
+	return o.Key(key).StartArrayFunc(fn)
+}
+
+// StartArrayFunc begins a new JSON array without a key whose items are added by fn.
+// The array is always closed after fn returns.
+//
+// Example:
+//
+//	obj.ArrayFunc("matrix", func(o *fson.Object) {
+//	    o.StartArrayFunc(func(o *fson.Object) { o.IntValue(1).IntValue(2) })
+//	})
+func (o *Object) StartArrayFunc(fn func(*Object)) *Object {
+	o.StartArray()
+	fn(o)
+	return o.EndArray()
+}
+
+// Lazy adds a key-value pair whose value is only computed if the field is actually going to be written.
+// fn should append exactly one value using the Value methods.
+//
+// Example:
+//
+//	obj.LazyFilter(func(key string) bool { return debug }).
+//	    Lazy("request", func(o *fson.Object) {
+//	        o.StringValue(dumpRequest(req)) // only called when debug is set
+//	    })
+//
+// Whether the field is wanted is decided by the filter set with LazyFilter. If no filter is set the
+// field is always written. When the field is not wanted neither the key nor the value is written and
+// fn is not called, which makes Lazy a cheap way to add expensive "debug only" fields on hot paths.
+func (o *Object) Lazy(key string, fn func(*Object)) *Object {
+	if o.lazyFilter != nil && !o.lazyFilter(key) {
+		return o
+	}
+
+	fn(o.Key(key))
+	return o
+}
+
+// LazyFilter sets the predicate that decides whether fields added with Lazy are written.
+// The predicate is called with the key of the field, return false to drop the field.
+//
+// Example:
+//
+//	obj.LazyFilter(func(key string) bool {
+//	    return level <= slog.LevelDebug || !strings.HasPrefix(key, "debug.")
+//	})
+//
+// A nil filter writes all lazy fields. The setting is kept across calls to Reset().
+func (o *Object) LazyFilter(filter func(key string) bool) *Object {
+	o.lazyFilter = filter
+	return o
+}
+
+// ObjectBuilder is a typed view of an open JSON object. It only offers methods that add key-value
+// pairs, so writing a bare value into an object is a compile error.
+//
+// Example:
+//
+//	obj.Builder().
+//	    String("name", "John").
+//	    Array("tags", func(a fson.ArrayBuilder) {
+//	        a.String("admin").Int(42)
+//	    }).
+//	    Object("address", func(b fson.ObjectBuilder) {
+//	        b.String("city", "Ghent")
+//	    })
+//	// Results in: {"name":"John","tags":["admin",42],"address":{"city":"Ghent"}}
+//
+// Nested objects and arrays are filled in by a function and closed as soon as it returns, which
+// makes it impossible to leave them unbalanced. A fluent End() that returns the parent builder would
+// need a builder type that is parameterized by the type of its parent, recursively, which Go's generics
+// don't allow. Generics are only used by ValueBuilder, which returns the container a value was written
+// to.
+//
+// The typed view only covers the most common values: strings, booleans, int, int64, uint64, float64,
+// times and null, besides nested objects and arrays. Anything else, slices included, can be written
+// with Any, or with the methods of the Object itself, which the builders write to. Calling
+// obj.Duration("timeout", d) inside a callback adds the member to the object that is being filled in.
+//
+// The builders are small values that wrap the Object, so they can be passed around freely. Note that
+// handing the Object to these functions makes it escape to the heap, see Init.
+type ObjectBuilder struct{ o *Object }
+
+// ArrayBuilder is a typed view of an open JSON array. It only offers methods that add values,
+// so writing a key into an array is a compile error. See ObjectBuilder.
+type ArrayBuilder struct{ o *Object }
+
+// ValueBuilder writes a single value into the container it was obtained from and returns the builder
+// of that container, which is an ObjectBuilder or an ArrayBuilder.
+//
+// Example:
+//
+//	b.Key("status").Int(200).String("msg", "ok")
+//
+// Exactly one of its methods must be called, which is the only thing the compiler can't check.
+type ValueBuilder[P ObjectBuilder | ArrayBuilder] struct{ o *Object }
+
+// Builder returns a typed view of the top-level object, see ObjectBuilder.
+// The Object itself is still used to Build the result.
+func (o *Object) Builder() ObjectBuilder { return ObjectBuilder{o: o} }
+
+// Key starts a key-value pair with the given key, the value is written with the returned ValueBuilder.
+func (b ObjectBuilder) Key(key string) ValueBuilder[ObjectBuilder] {
+	b.o.Key(key)
+	return ValueBuilder[ObjectBuilder]{o: b.o}
+}
+
+// String adds a string key-value pair.
+func (b ObjectBuilder) String(key, value string) ObjectBuilder { return b.Key(key).String(value) }
+
+// Bool adds a boolean key-value pair.
+func (b ObjectBuilder) Bool(key string, value bool) ObjectBuilder { return b.Key(key).Bool(value) }
+
+// Int adds an integer key-value pair.
+func (b ObjectBuilder) Int(key string, value int) ObjectBuilder { return b.Key(key).Int(value) }
+
+// Int64 adds a 64-bit integer key-value pair.
+func (b ObjectBuilder) Int64(key string, value int64) ObjectBuilder { return b.Key(key).Int64(value) }
+
+// Uint64 adds an unsigned 64-bit integer key-value pair.
+func (b ObjectBuilder) Uint64(key string, value uint64) ObjectBuilder {
+	return b.Key(key).Uint64(value)
+}
+
+// Float64 adds a 64-bit floating point key-value pair.
+func (b ObjectBuilder) Float64(key string, value float64) ObjectBuilder {
+	return b.Key(key).Float64(value)
+}
+
+// Time adds a time key-value pair formatted with the given layout.
+func (b ObjectBuilder) Time(key string, value time.Time, format string) ObjectBuilder {
+	return b.Key(key).Time(value, format)
+}
+
+// Null adds a key with a null value.
+func (b ObjectBuilder) Null(key string) ObjectBuilder { return b.Key(key).Null() }
+
+// Any adds a key-value pair of any type, see Object.AnyValue.
+func (b ObjectBuilder) Any(key string, value any) ObjectBuilder { return b.Key(key).Any(value) }
+
+// Object adds a nested object with the given key whose members are added by fn.
+func (b ObjectBuilder) Object(key string, fn func(ObjectBuilder)) ObjectBuilder {
+	return b.Key(key).Object(fn)
+}
+
+// Array adds an array with the given key whose values are added by fn.
+func (b ObjectBuilder) Array(key string, fn func(ArrayBuilder)) ObjectBuilder {
+	return b.Key(key).Array(fn)
+}
+
+// Value starts the next value of the array, which is written with the returned ValueBuilder.
+func (b ArrayBuilder) Value() ValueBuilder[ArrayBuilder] { return ValueBuilder[ArrayBuilder]{o: b.o} }
+
+// String adds a string value.
+func (b ArrayBuilder) String(value string) ArrayBuilder { return b.Value().String(value) }
+
+// Bool adds a boolean value.
+func (b ArrayBuilder) Bool(value bool) ArrayBuilder { return b.Value().Bool(value) }
+
+// Int adds an integer value.
+func (b ArrayBuilder) Int(value int) ArrayBuilder { return b.Value().Int(value) }
+
+// Int64 adds a 64-bit integer value.
+func (b ArrayBuilder) Int64(value int64) ArrayBuilder { return b.Value().Int64(value) }
+
+// Uint64 adds an unsigned 64-bit integer value.
+func (b ArrayBuilder) Uint64(value uint64) ArrayBuilder { return b.Value().Uint64(value) }
+
+// Float64 adds a 64-bit floating point value.
+func (b ArrayBuilder) Float64(value float64) ArrayBuilder { return b.Value().Float64(value) }
+
+// Time adds a time value formatted with the given layout.
+func (b ArrayBuilder) Time(value time.Time, format string) ArrayBuilder {
+	return b.Value().Time(value, format)
+}
+
+// Null adds a null value.
+func (b ArrayBuilder) Null() ArrayBuilder { return b.Value().Null() }
+
+// Any adds a value of any type, see Object.AnyValue.
+func (b ArrayBuilder) Any(value any) ArrayBuilder { return b.Value().Any(value) }
+
+// Object adds a nested object whose members are added by fn.
+func (b ArrayBuilder) Object(fn func(ObjectBuilder)) ArrayBuilder { return b.Value().Object(fn) }
+
+// Array adds a nested array whose values are added by fn.
+func (b ArrayBuilder) Array(fn func(ArrayBuilder)) ArrayBuilder { return b.Value().Array(fn) }
+
+// String writes a string value.
+func (v ValueBuilder[P]) String(value string) P {
+	v.o.StringValue(value)
+	return P{o: v.o}
+}
+
+// Bool writes a boolean value.
+func (v ValueBuilder[P]) Bool(value bool) P {
+	v.o.BoolValue(value)
+	return P{o: v.o}
+}
+
+// Int writes an integer value.
+func (v ValueBuilder[P]) Int(value int) P {
+	v.o.IntValue(value)
+	return P{o: v.o}
+}
+
+// Int64 writes a 64-bit integer value.
+func (v ValueBuilder[P]) Int64(value int64) P {
+	v.o.Int64Value(value)
+	return P{o: v.o}
+}
+
+// Uint64 writes an unsigned 64-bit integer value.
+func (v ValueBuilder[P]) Uint64(value uint64) P {
+	v.o.Uint64Value(value)
+	return P{o: v.o}
+}
+
+// Float64 writes a 64-bit floating point value.
+func (v ValueBuilder[P]) Float64(value float64) P {
+	v.o.Float64Value(value)
+	return P{o: v.o}
+}
+
+// Time writes a time value formatted with the given layout.
+func (v ValueBuilder[P]) Time(value time.Time, format string) P {
+	v.o.TimeValue(value, format)
+	return P{o: v.o}
+}
+
+// Null writes a null value.
+func (v ValueBuilder[P]) Null() P {
+	v.o.NullValue()
+	return P{o: v.o}
+}
+
+// Any writes a value of any type, see Object.AnyValue.
+func (v ValueBuilder[P]) Any(value any) P {
+	v.o.AnyValue(value)
+	return P{o: v.o}
+}
+
+// Object writes a nested object whose members are added by fn. The object is closed after fn returns.
+func (v ValueBuilder[P]) Object(fn func(ObjectBuilder)) P {
+	v.o.StartObject()
+	fn(ObjectBuilder{o: v.o})
+	v.o.EndObject()
+	return P{o: v.o}
+}
+
+// Array writes a nested array whose values are added by fn. The array is closed after fn returns.
+func (v ValueBuilder[P]) Array(fn func(ArrayBuilder)) P {
+	v.o.StartArray()
+	fn(ArrayBuilder{o: v.o})
+	v.o.EndArray()
+	return P{o: v.o}
+}
+
+// Seq appends the values produced by seq as an array with the given key.
+// Each value is handed to appendFn, which should append exactly one value using the Value methods.
+//
+// Example:
+//
+//	fson.Seq(obj, "names", slices.Values(users), func(o *fson.Object, u User) {
+//	    o.StringValue(u.Name)
+//	})
+//	// Results in: {"names":["Alice","Bob"]}
+//
+// Values are written as they are produced, so lazily generated data like rows from a database cursor
+// can be streamed straight into the buffer without collecting them in a slice first.
+func Seq[T any](o *Object, key string, seq iter.Seq[T], appendFn func(*Object, T)) *Object {
+	return SeqValue(o.Key(key), seq, appendFn)
+}
+
+// SeqValue appends the values produced by seq as an array to the current key in the JSON object.
+// Each value is handed to appendFn, which should append exactly one value using the Value methods.
+//
+// Example:
+//
+//	fson.SeqValue(obj.Key("names"), slices.Values(users), func(o *fson.Object, u User) {
+//	    o.StringValue(u.Name)
+//	})
+func SeqValue[T any](o *Object, seq iter.Seq[T], appendFn func(*Object, T)) *Object {
+	o.StartArray()
+	for v := range seq {
+		appendFn(o, v)
+	}
+	return o.EndArray()
+}
+
+// Seq2 appends the key-value pairs produced by seq as a nested object with the given key.
+// For each pair the key is written first after which the value is handed to appendFn, which should
+// append exactly one value using the Value methods.
+//
+// Example:
+//
+//	fson.Seq2(obj, "scores", maps.All(scores), func(o *fson.Object, score int) {
+//	    o.IntValue(score)
+//	})
+//	// Results in: {"scores":{"alice":10,"bob":7}}
+//
+// Just like with a regular map, it is up to the caller to make sure the keys are unique.
+func Seq2[K ~string, V any](o *Object, key string, seq iter.Seq2[K, V], appendFn func(*Object, V)) *Object {
+	return Seq2Value(o.Key(key), seq, appendFn)
+}
+
+// Seq2Value appends the key-value pairs produced by seq as a nested object to the current key in the
+// JSON object.
+//
+// Example:
+//
+//	fson.Seq2Value(obj.Key("scores"), maps.All(scores), func(o *fson.Object, score int) {
+//	    o.IntValue(score)
+//	})
+func Seq2Value[K ~string, V any](o *Object, seq iter.Seq2[K, V], appendFn func(*Object, V)) *Object {
+	o.StartObject()
+	for k, v := range seq {
+		appendFn(o.Key(string(k)), v)
+	}
+	return o.EndObject()
+}
+
+// SignedNumber is a constraint that permits any type whose underlying type is a signed integer.
+type SignedNumber interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+// UnsignedNumber is a constraint that permits any type whose underlying type is an unsigned integer.
+type UnsignedNumber interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// FloatNumber is a constraint that permits any type whose underlying type is a floating-point number.
+type FloatNumber interface {
+	~float32 | ~float64
+}
+
+// Int appends a signed integer key-value pair to the JSON object.
+// Unlike the Int method it accepts any type with a signed integer underlying type.
+//
+// Example:
+//
+//	type UserID int64
+//	fson.Int(obj, "user", UserID(42))
+func Int[T SignedNumber](o *Object, key string, value T) *Object {
+	return o.Key(key).Int64Value(int64(value))
+}
+
+// IntValue appends a signed integer value of any type with a signed integer underlying type
+// to the current key in the JSON object.
+//
+// Example:
+//
+//	fson.IntValue(obj.Key("user"), UserID(42))
+func IntValue[T SignedNumber](o *Object, value T) *Object {
+	return o.Int64Value(int64(value))
+}
+
+// Ints appends an array of signed integers as a key-value pair to the JSON object.
+// Unlike the Ints method it accepts slices of any type with a signed integer underlying type.
+//
+// Example:
+//
+//	fson.Ints(obj, "users", []UserID{1, 2, 3})
+func Ints[T SignedNumber](o *Object, key string, value []T) *Object {
+	return IntsValue(o.Key(key), value)
+}
+
+// IntsValue appends an array of signed integers of any type with a signed integer underlying type
+// to the current key in the JSON object.
+//
+// Example:
+//
+//	fson.IntsValue(obj.Key("users"), []UserID{1, 2, 3})
+func IntsValue[T SignedNumber](o *Object, value []T) *Object {
+	size := func(value T) int { return intLen(int64(value)) }
+	return appendArrayValue(o, value, size, func(buf []byte, value T) []byte {
+		return strconv.AppendInt(buf, int64(value), 10)
+	})
+}
+
+// Uint appends an unsigned integer key-value pair to the JSON object.
+// Unlike the Uint method it accepts any type with an unsigned integer underlying type.
+//
+// Example:
+//
+//	type Port uint16
+//	fson.Uint(obj, "port", Port(8080))
+func Uint[T UnsignedNumber](o *Object, key string, value T) *Object {
+	return o.Key(key).Uint64Value(uint64(value))
+}
+
+// UintValue appends an unsigned integer value of any type with an unsigned integer underlying type
+// to the current key in the JSON object.
+//
+// Example:
+//
+//	fson.UintValue(obj.Key("port"), Port(8080))
+func UintValue[T UnsignedNumber](o *Object, value T) *Object {
+	return o.Uint64Value(uint64(value))
+}
+
+// Uints appends an array of unsigned integers as a key-value pair to the JSON object.
+// Unlike the Uints method it accepts slices of any type with an unsigned integer underlying type.
+//
+// Example:
+//
+//	fson.Uints(obj, "ports", []Port{80, 443})
+func Uints[T UnsignedNumber](o *Object, key string, value []T) *Object {
+	return UintsValue(o.Key(key), value)
+}
+
+// UintsValue appends an array of unsigned integers of any type with an unsigned integer underlying
+// type to the current key in the JSON object.
+//
+// Example:
+//
+//	fson.UintsValue(obj.Key("ports"), []Port{80, 443})
+func UintsValue[T UnsignedNumber](o *Object, value []T) *Object {
+	size := func(value T) int { return uintLen(uint64(value)) }
+	return appendArrayValue(o, value, size, func(buf []byte, value T) []byte {
+		return strconv.AppendUint(buf, uint64(value), 10)
+	})
+}
+
+// Float appends a floating-point key-value pair to the JSON object.
+// Unlike the Float32 and Float64 methods it accepts any type with a floating-point underlying type.
+// Values with an underlying float32 type are formatted with 32-bit precision, just like Float32.
+//
+// Example:
+//
+//	type Celsius float64
+//	fson.Float(obj, "temperature", Celsius(21.5))
+//
+// Note: Special values like NaN and Infinity will be encoded as string values
+// rather than JSON numbers, as JSON does not support these values as numbers.
+func Float[T FloatNumber](o *Object, key string, value T) *Object {
+	return FloatValue(o.Key(key), value)
+}
+
+// FloatValue appends a floating-point value of any type with a floating-point underlying type
+// to the current key in the JSON object.
+//
+// Example:
+//
+//	fson.FloatValue(obj.Key("temperature"), Celsius(21.5))
+//
+// Note: Special values like NaN and Infinity will be encoded as string values
+// rather than JSON numbers, as JSON does not support these values as numbers.
+func FloatValue[T FloatNumber](o *Object, value T) *Object {
+	if o.fixed && !o.reserve(floatLen(float64(value))+1) {
+		return o
+	}
+	o.buf = appendFloat(o.buf, float64(value), floatBitSize[T]())
+	o.buf = append(o.buf, ',')
+	return o
+}
+
+// Floats appends an array of floating-point values as a key-value pair to the JSON object.
+// Unlike the Floats32 and Floats64 methods it accepts slices of any type with a floating-point
+// underlying type.
+//
+// Example:
+//
+//	fson.Floats(obj, "temperatures", []Celsius{21.5, 22})
+//
+// Note: Special values like NaN and Infinity will be encoded as string values
+// rather than JSON numbers, see Floats64 for the implications.
+func Floats[T FloatNumber](o *Object, key string, value []T) *Object {
+	return FloatsValue(o.Key(key), value)
+}
+
+// FloatsValue appends an array of floating-point values of any type with a floating-point underlying
+// type to the current key in the JSON object.
+//
+// Example:
+//
+//	fson.FloatsValue(obj.Key("temperatures"), []Celsius{21.5, 22})
+//
+// Note: Special values like NaN and Infinity will be encoded as string values
+// rather than JSON numbers, as JSON does not support these values as numbers.
+func FloatsValue[T FloatNumber](o *Object, value []T) *Object {
+	bitSize := floatBitSize[T]()
+	size := func(value T) int { return floatLen(float64(value)) }
+	return appendArrayValue(o, value, size, func(buf []byte, value T) []byte {
+		return appendFloat(buf, float64(value), bitSize)
+	})
+}
+
+// floatBitSize returns 32 if the underlying type of T is float32 and 64 otherwise.
+// 1+2⁻³⁰ is exact in a float64 but needs more than the 24 bits of precision of a float32, so it only
+// survives a round trip through T if T is 64 bits wide. Converting a value that is within range to a
+// narrower float rounds it, which is well defined, unlike converting a value that is out of range.
+func floatBitSize[T FloatNumber]() int {
+	v := 1 + 1.0/(1<<30)
+	if float64(T(v)) != v {
+		return 32
+	}
+	return 64
+}
+
+// Build finalizes the JSON object and returns the resulting byte slice.
+// This should be called once, after all key-value pairs have been added.
+//
+// If the object is empty, it returns "{}".
+//
+// Example:
+//
+//	buf := make([]byte, 0, 1024)
+//	json := fson.NewObject(buf).
+//	    String("name", "John").
+//	    Int("age", 30).
+//	    Build()
+//	fmt.Println(string(json))
+//
+// IMPORTANT: The returned byte slice references the same underlying memory as
+// the input buffer. If you need to reuse the buffer for another JSON object,
+// make sure to copy the result first or process it before reusing the buffer.
+func (o *Object) Build() []byte {
+	if o.fixed {
+		return o.buildFixed()
+	}
+	return o.build()
+}
+
+// build closes the top-level object.
+func (o *Object) build() []byte {
+	// A built object ends with its closing brace, don't add the marker twice.
+	if o.maxSize > 0 && o.buf[len(o.buf)-1] != '}' {
+		o.endMember()
+		if o.truncated {
+			o.buf = append(o.buf, `"_truncated":true,`...)
+		}
+	}
+
+	if o.buf[len(o.buf)-1] != '{' {
+		o.buf[len(o.buf)-1] = '}'
+		return o.buf
+	}
+
+	o.buf = append(o.buf, '}')
+	return o.buf
+}
+
+// buildFixed builds the object in fixed-capacity mode, returning nil if it didn't fit.
+func (o *Object) buildFixed() []byte {
+	if o.overflowed {
+		return nil
+	}
+
+	// build only appends the truncation marker, or the closing brace of an empty object. The last member
+	// is ended first to find out whether the marker is needed, build won't end it again.
+	built := o.buf[len(o.buf)-1] == '}'
+	if o.maxSize > 0 && !built {
+		o.endMember()
+	}
+	n := 0
+	switch {
+	case o.maxSize > 0 && !built && o.truncated:
+		n = len(`"_truncated":true,`)
+	case o.buf[len(o.buf)-1] == '{':
+		n = 1
+	}
+	if !o.reserve(n) {
+		return nil
+	}
+	return o.build()
+}
+
+// BuildErr builds the JSON object like Build and returns the first error recorded while encoding,
+// see Err(). For an Object created with NewFixedObject that overflowed, it returns nil and ErrBufferFull.
+func (o *Object) BuildErr() ([]byte, error) {
+	b := o.Build()
+	if o.overflowed {
+		return nil, ErrBufferFull
+	}
+	return b, o.err
+}
+
+// Overflowed reports whether a write did not fit in the buffer of an Object created with NewFixedObject.
+func (o *Object) Overflowed() bool { return o.overflowed }
+
+// reserve reports whether n more bytes fit in the buffer in fixed-capacity mode. If they don't, the
+// Object is marked as overflowed and the caller must skip the write, so that the buffer is never
+// reallocated. Once the Object overflowed nothing fits anymore.
+func (o *Object) reserve(n int) bool {
+	if !o.overflowed && len(o.buf)+n <= cap(o.buf) {
+		return true
+	}
+	o.overflow()
+	return false
+}
+
+// overflow marks the Object as overflowed.
+func (o *Object) overflow() {
+	if !o.overflowed {
+		o.overflowed = true
+		o.setErr(ErrBufferFull)
+	}
+}
+
+// reserveClose is reserve for closing the innermost container, which was opened with open.
+// The closing bracket replaces the trailing comma, unless the container is empty.
+func (o *Object) reserveClose(open byte) bool {
+	if o.buf[len(o.buf)-1] == open {
+		return o.reserve(2)
+	}
+	return o.reserve(1)
+}
+
+// CloseAll closes every object and array that is still open, so that Build produces valid JSON
+// from whatever was written so far.
+//
+// Example:
+//
+//	obj.Object("request").String("method", "GET").Array("headers").StringValue("a").Key("dangling")
+//	obj.CloseAll().Build()
+//	// Results in: {"request":{"method":"GET","headers":["a"]}}
+//
+// A key that is still waiting for its value is dropped, and a string that is being written with a
+// StringWriter or Base64Writer is closed with what was written to it. Containers are closed exactly
+// like their End method would, so limits such as MaxDepth and MaxSize still apply.
+//
+// This is mostly useful when encoding was abandoned halfway, see BuildPartial.
+func (o *Object) CloseAll() *Object {
+	// Closing the writers through their own pointer to o would make o escape to the heap.
+	if o.stringWriter.o != nil && !o.stringWriter.closed {
+		o.stringWriter.finish(o)
+	}
+	if o.base64Writer.o != nil && !o.base64Writer.closed {
+		o.base64Writer.finish(o)
+	}
+
+	for {
+		kind := containerObject
+		if c := o.top(); c != nil {
+			kind = c.kind
+		}
+		if kind != containerArray {
+			o.dropDanglingKey()
+		}
+		if o.depth == 0 {
+			return o
+		}
+
+		switch kind {
+		case containerObject:
+			o.EndObject()
+		case containerArray:
+			o.EndArray()
+		case containerObjectString:
+			o.EndObjectString()
+		}
+	}
+}
+
+// BuildPartial closes every object and array that is still open and builds the JSON object,
+// see CloseAll and Build.
+//
+// It always returns valid JSON, which makes it suitable for salvaging a document when an encoder
+// panics or returns early. Since the top-level object stays open after CloseAll, members can still
+// be added to it before building:
+//
+//	defer func() {
+//	    if r := recover(); r != nil {
+//	        log.Write(obj.CloseAll().String("panic", fmt.Sprint(r)).Build())
+//	    }
+//	}()
+func (o *Object) BuildPartial() []byte {
+	return o.CloseAll().Build()
+}
+
+// dropDanglingKey removes a key at the end of the buffer that has no value yet.
+func (o *Object) dropDanglingKey() {
+	if len(o.buf) < 3 || o.buf[len(o.buf)-1] != ':' {
+		return
+	}
+
+	// Find the opening quote of the key. A quote inside it is escaped by an odd number of backslashes.
+	for i := len(o.buf) - 3; i >= 0; i-- {
+		if o.buf[i] != '"' {
+			continue
+		}
+		backslashes := 0
+		for j := i - 1; j >= 0 && o.buf[j] == '\\'; j-- {
+			backslashes++
+		}
+		if backslashes%2 == 0 {
+			o.buf = o.buf[:i]
+			return
+		}
+	}
+}
+
+// Reset resets the underlying buffer and prepares the Object for reuse.
+// It clears all existing JSON content, truncates the buffer to length 0
+// and adds the opening brace '{' to start a new JSON object.
+//
+// After Reset(), the object is in the initial state as if newly created with
+// NewObject() - any previous structure is completely discarded.
+func (o *Object) Reset() *Object {
+	if o.fixed {
+		o.buf = o.fixedBuf
+		o.overflowed = false
+	}
+	o.buf = o.buf[:0]
+	o.err = nil
+	o.depth = 0
+	o.truncated = false
+	o.member = member{}
+	o.stringWriter.closed = true
+	o.base64Writer.closed = true
+	if !o.fixed || o.reserve(1) {
+		o.buf = append(o.buf, '{')
+	}
+	return o
+}
+
+// ErrMaxDepth is recorded when a container exceeds the maximum depth set with MaxDepth without a placeholder.
+var ErrMaxDepth = errors.New("fson: maximum nesting depth exceeded")
+
+// DefaultDepthPlaceholder is a suggested placeholder for MaxDepth.
+const DefaultDepthPlaceholder = "<max depth>"
+
+// MaxDepth limits how deep objects and arrays can be nested. The top-level object has a depth of 1,
+// so MaxDepth(2) allows {"a":{"b":1}} but not {"a":{"b":{}}}.
+//
+// Example:
+//
+//	obj.MaxDepth(2, fson.DefaultDepthPlaceholder).
+//	    Object("a").Object("b").Int("c", 1).EndObject().EndObject()
+//	// Results in: {"a":{"b":"<max depth>"}}
+//
+// A container that would exceed the maximum depth, including everything inside it, is replaced by the
+// placeholder string once it is closed, which keeps the output well-formed. If placeholder is empty it
+// is replaced by null instead and ErrMaxDepth is recorded, see Err().
+//
+// This protects consumers from absurdly deep JSON produced by recursive encoders over user-supplied
+// data. Note that the replaced subtree is still encoded before it is discarded, recursive encoders can
+// use Depth() to stop early. A depth of 0 or less removes the limit, which is the default.
+// The setting is kept across calls to Reset().
+func (o *Object) MaxDepth(depth int, placeholder string) *Object {
+	o.maxDepth = depth
+	o.depthPlaceholder = placeholder
+	return o
+}
+
+// Depth returns the current nesting depth: 1 for the top-level object, plus one for every object or
+// array that is currently open inside it.
+func (o *Object) Depth() int { return o.depth + 1 }
+
+// Savepoint marks a position in an Object that it can be rolled back to with Rollback.
+type Savepoint struct {
+	size       int    // length of the buffer
+	depth      int    // number of open containers
+	err        error  // error recorded at the time
+	truncated  bool   // whether members were dropped at the time
+	member     member // member of the top-level object at the time
+	removals   int    // members removed by MergeKeepIncoming at the time
+	overflowed bool   // whether a write did not fit in fixed-capacity mode at the time
+
+	// Members of the open containers at the time, only recorded while a MaxSize budget is set
+	members [inlineStackSize]member // members of the outermost open containers
+	spill   []member                // members of the open containers beyond inlineStackSize
+}
+
+// Savepoint returns a savepoint for the current state of the Object.
 //
-// If the array is empty (no items added), it will output "[]".
-// Otherwise, it will replace the trailing comma with a closing bracket.
+// Together with Rollback this makes it possible to abandon a member, or a whole nested object,
+// that could not be encoded completely, without leaving partial bytes behind:
 //
-// IMPORTANT: Each call to Array()/StartArray() must be paired with a call to EndArray().
-// Unbalanced calls may result in invalid JSON.
-func (o *Object) EndArray() *Object {
-	// If the array is empty just append the closing array tag
-	// otherwise replace the final , with a closing array tag
-	if o.buf[len(o.buf)-1] == '[' {
-		o.buf = append(o.buf, ']')
-	} else {
-		o.buf[len(o.buf)-1] = ']'
+//	sp := obj.Savepoint()
+//	obj.Object("payment")
+//	if err := encodePayment(obj, p); err != nil {
+//	    obj.Rollback(sp) // "payment" and everything written for it is gone
+//	} else {
+//	    obj.EndObject()
+//	}
+//
+// Taking a savepoint is cheap, it doesn't copy any data. Only with a MaxSize budget set and more than
+// four objects or arrays open does it allocate, to record which member each of them is writing.
+func (o *Object) Savepoint() Savepoint {
+	sp := Savepoint{
+		size:       len(o.buf),
+		depth:      o.depth,
+		err:        o.err,
+		truncated:  o.truncated,
+		member:     o.member,
+		removals:   o.removals,
+		overflowed: o.overflowed,
 	}
+	if o.maxSize > 0 {
+		for i := range o.depth {
+			if i < inlineStackSize {
+				sp.members[i] = o.at(i).member
+			} else {
+				sp.spill = append(sp.spill, o.at(i).member)
+			}
+		}
+	}
+	return sp
+}
 
-	o.buf = append(o.buf, ',')
+// Rollback restores the Object to the state it was in when sp was taken.
+// Everything written since is discarded, including keys, values and containers that were opened,
+// and the error recorded at that time is restored, see Err(). In fixed-capacity mode this includes
+// an overflow: the writes that didn't fit are discarded, so the Object can be written to again.
+//
+// Containers that were open when the savepoint was taken are expected to still be open. Rolling
+// back past the end of such a container is only possible as long as no other container has been
+// opened at the same level since, otherwise Rollback panics. It also panics if MergeObject has
+// removed existing members with MergeKeepIncoming since sp was taken, because the bytes that sp
+// refers to have moved.
+//
+// Rolling back invalidates any savepoints taken after sp, as well as open StringWriters and
+// Base64Writers, which will report ErrWriterClosed.
+func (o *Object) Rollback(sp Savepoint) *Object {
+	// Containers that were open at the savepoint may have been closed since. Popping doesn't clear
+	// them, so they can be brought back as long as they haven't been overwritten by a container that
+	// was opened after the savepoint, which necessarily starts at or after sp.size.
+	for i := range sp.depth {
+		if c := o.at(i); c == nil || c.kind == 0 || c.start >= sp.size {
+			panic("fson: cannot roll back to savepoint, its containers are no longer known")
+		}
+	}
+	if o.removals != sp.removals {
+		panic("fson: cannot roll back to savepoint, MergeObject has removed members since")
+	}
+
+	o.buf = o.buf[:sp.size]
+	o.depth = sp.depth
+	o.err = sp.err
+	o.truncated = sp.truncated
+	o.member = sp.member
+	o.overflowed = sp.overflowed
+	if o.maxSize > 0 {
+		for i := range sp.depth {
+			var m member
+			if i < inlineStackSize {
+				m = sp.members[i]
+			} else if j := i - inlineStackSize; j < len(sp.spill) {
+				m = sp.spill[j]
+			}
+			o.at(i).member = m
+		}
+	}
+	o.stringWriter.closed = true
+	o.base64Writer.closed = true
 	return o
 }
 
-// Build finalizes the JSON object and returns the resulting byte slice.
-// This should be called once, after all key-value pairs have been added.
-//
-// If the object is empty, it returns "{}".
+// DefaultTruncateSuffix is a suggested suffix for MaxStringSize.
+const DefaultTruncateSuffix = "...(truncated %d bytes)"
+
+// MaxSize sets a budget of size bytes for the document.
 //
 // Example:
 //
-//	buf := make([]byte, 0, 1024)
-//	json := fson.NewObject(buf).
-//	    String("name", "John").
-//	    Int("age", 30).
-//	    Build()
-//	fmt.Println(string(json))
-//
-// IMPORTANT: The returned byte slice references the same underlying memory as
-// the input buffer. If you need to reuse the buffer for another JSON object,
-// make sure to copy the result first or process it before reusing the buffer.
-func (o *Object) Build() []byte {
-	if o.buf[len(o.buf)-1] != '{' {
-		o.buf[len(o.buf)-1] = '}'
-		return o.buf
-	}
-
-	o.buf = append(o.buf, '}')
-	return o.buf
+//	obj.MaxSize(32).String("a", "short").String("b", strings.Repeat("x", 64)).Int("c", 1)
+//	// Results in: {"a":"short","_truncated":true}
+//
+// Once a member pushes the document over the budget it is dropped, along with every member that
+// follows it, and a "_truncated":true member is added by Build. Members are dropped as a whole,
+// including any objects and arrays they contain, which keeps the output well-formed. Strings that don't
+// fit in what is left of the budget are not copied into the buffer, including those streamed with
+// StringWriter, Base64Writer and ReadStringFrom. Other values of a dropped member are still encoded before they are
+// discarded, so the buffer can temporarily exceed the budget by the size of one member. The closing
+// brackets and the truncation marker can also make the result slightly larger than the budget.
+//
+// Members are the key-value pairs of objects, the elements of arrays are not dropped on their own.
+// A size of 0 or less removes the budget, which is the default. The setting is kept across calls to Reset().
+func (o *Object) MaxSize(size int) *Object {
+	o.maxSize = size
+	return o
 }
 
-// Reset resets the underlying buffer and prepares the Object for reuse.
-// It clears all existing JSON content, truncates the buffer to length 0
-// and adds the opening brace '{' to start a new JSON object.
+// MaxStringSize limits string values to size bytes.
 //
-// After Reset(), the object is in the initial state as if newly created with
-// NewObject() - any previous structure is completely discarded.
-func (o *Object) Reset() *Object {
-	o.buf = o.buf[:0]
-	o.buf = append(o.buf, '{')
+// Example:
+//
+//	obj.MaxStringSize(4, fson.DefaultTruncateSuffix).String("msg", "hello world")
+//	// Results in: {"msg":"hell...(truncated 7 bytes)"}
+//
+// Longer strings are cut at a UTF-8 boundary at or before size bytes, and suffix is appended to them.
+// The first %d in suffix is replaced by the number of bytes that were cut off. The limit applies to
+// every string value, including those written with StringWriter, ReadStringFrom, Text and ObjectString,
+// and is measured before escaping. Keys and the values of Base64Writer are never truncated. An object
+// embedded with ObjectString is never cut short, as that would leave invalid JSON behind: if it is longer
+// than size it is replaced by the suffix as a whole.
+//
+// A size of 0 or less removes the limit, which is the default. The setting is kept across calls to Reset().
+func (o *Object) MaxStringSize(size int, suffix string) *Object {
+	o.maxStringSize = size
+	o.truncateSuffix = suffix
 	return o
 }
 
@@ -1059,6 +4257,154 @@ func (o *Object) Size() int { return len(o.buf) }
 // Cap returns the capacity of the underlying buffer
 func (o *Object) Cap() int { return cap(o.buf) }
 
+// Err returns the first error that was encountered while encoding, for example a failing
+// MarshalText call. The JSON produced so far stays valid, the offending value is replaced
+// by null. Err is cleared by Reset().
+func (o *Object) Err() error { return o.err }
+
+// containerKind identifies the type of an open container.
+type containerKind uint8
+
+const (
+	containerObject       containerKind = iota + 1 // started with StartObject
+	containerArray                                 // started with StartArray
+	containerObjectString                          // started with StartObjectString
+)
+
+// container is an open object or array.
+type container struct {
+	start   int    // offset of the opening bracket in buf
+	member  member // member of the container that is being written, only tracked for objects
+	kind    containerKind
+	tooDeep bool // the container exceeds the maximum depth and is replaced once it is closed
+}
+
+// member marks the start of a key-value pair while a size budget is set with MaxSize.
+type member struct {
+	start int  // offset of the key in buf, 0 if no member was started
+	drop  bool // the member was started after the budget was exceeded and is always dropped
+}
+
+// startMember ends the previous member of the innermost object and records the start of a new one.
+func (o *Object) startMember() {
+	m := o.endMember()
+	*m = member{start: len(o.buf), drop: o.truncated}
+}
+
+// endMember drops the member that was last started in the innermost object if it pushed the document
+// over the budget, or if it was started after the budget was already exceeded. Only complete members are
+// dropped, so the output stays well-formed.
+func (o *Object) endMember() *member {
+	m := &o.member
+	if c := o.top(); c != nil {
+		m = &c.member
+	}
+
+	// A Rollback can leave the start of a member that no longer exists behind.
+	if m.start > len(o.buf) {
+		*m = member{}
+	}
+	if m.start > 0 && (m.drop || len(o.buf) > o.maxSize) {
+		o.buf = o.buf[:m.start]
+		o.truncated = true
+	}
+	*m = member{}
+	return m
+}
+
+// overBudget reports whether appending n more bytes pushes the document over the MaxSize budget. If so,
+// the member that is being written in the innermost object is marked to be dropped, which allows the
+// caller to skip appending the bytes altogether.
+func (o *Object) overBudget(n int) bool {
+	if o.maxSize <= 0 || len(o.buf)+n <= o.maxSize {
+		return false
+	}
+
+	// Elements of arrays aren't members, the member they belong to is in an enclosing object.
+	m := &o.member
+	for i := o.depth - 1; i >= 0; i-- {
+		if c := o.at(i); c.kind != containerArray {
+			m = &c.member
+			break
+		}
+	}
+	if m.start == 0 || m.start > len(o.buf) {
+		return false
+	}
+	m.drop = true
+	return true
+}
+
+// push records that a container of the given kind is opened at the end of the buffer.
+func (o *Object) push(kind containerKind) {
+	// The new container ends up at depth o.depth+2. Only the outermost container that is too
+	// deep is marked, anything inside it is discarded along with it.
+	tooDeep := o.maxDepth > 0 && o.depth+2 == o.maxDepth+1
+	c := container{kind: kind, start: len(o.buf), tooDeep: tooDeep}
+
+	// Closed containers are left behind in the stack, which allows Rollback to reopen them.
+	if i := o.depth - inlineStackSize; i >= len(o.spill) {
+		o.spill = append(o.spill, c)
+	} else {
+		*o.at(o.depth) = c
+	}
+	o.depth++
+}
+
+// inlineStackSize is the number of open containers that is stored inside the Object itself.
+const inlineStackSize = 4
+
+// at returns the i-th open container, counting from the outermost one, or nil if it isn't known.
+func (o *Object) at(i int) *container {
+	if i < inlineStackSize {
+		return &o.stack[i]
+	}
+	if i -= inlineStackSize; i < len(o.spill) {
+		return &o.spill[i]
+	}
+	return nil
+}
+
+// top returns the innermost open container, or nil if only the top-level object is open.
+func (o *Object) top() *container {
+	if o.depth == 0 {
+		return nil
+	}
+	return o.at(o.depth - 1)
+}
+
+// replaceTooDeep replaces the container c, which was just closed, by the depth placeholder.
+func (o *Object) replaceTooDeep(c container) *Object {
+	start := c.start
+	if c.kind == containerObjectString {
+		start-- // also drop the opening quote
+	}
+	o.buf = o.buf[:start]
+
+	if o.depthPlaceholder == "" {
+		o.setErr(ErrMaxDepth)
+		return o.NullValue()
+	}
+	return o.StringValue(o.depthPlaceholder)
+}
+
+// pop removes the innermost open container and returns it.
+// Unbalanced calls that would close the top-level object are ignored, like they always have been.
+func (o *Object) pop() container {
+	if o.depth == 0 {
+		return container{}
+	}
+	o.depth--
+	return *o.at(o.depth)
+}
+
+// setErr records err unless an earlier error was already recorded.
+func (o *Object) setErr(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
 func appendString(buf []byte, s string) []byte {
 	buf = append(buf, '"')
 	buf = safeAppendString(
@@ -1069,6 +4415,260 @@ func appendString(buf []byte, s string) []byte {
 	return append(buf, '"')
 }
 
+// appendTruncatedString appends s as a JSON string, cut to at most limit bytes followed by suffix if it is longer.
+func appendTruncatedString(buf []byte, s string, limit int, suffix string) []byte {
+	if len(s) <= limit {
+		return appendString(buf, s)
+	}
+
+	n := limit
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	buf = append(buf, '"')
+	buf = safeAppendString(utf8.DecodeRuneInString, buf, s[:n])
+	buf = appendTruncateSuffix(buf, suffix, len(s)-n)
+	return append(buf, '"')
+}
+
+// appendTruncateSuffix appends the escaped suffix with its first %d replaced by cut.
+func appendTruncateSuffix(buf []byte, suffix string, cut int) []byte {
+	if i := strings.Index(suffix, "%d"); i >= 0 {
+		buf = safeAppendString(utf8.DecodeRuneInString, buf, suffix[:i])
+		buf = strconv.AppendInt(buf, int64(cut), 10)
+		suffix = suffix[i+2:]
+	}
+	return safeAppendString(utf8.DecodeRuneInString, buf, suffix)
+}
+
+// closeStringValue closes the string that was appended to the buffer unescaped like closeString, after
+// cutting it off at the MaxStringSize limit, and ends the value. dropped is the number of bytes that were
+// already cut off while appending it.
+func (o *Object) closeStringValue(start, dropped int) {
+	cut := 0
+	if limit := o.maxStringSize; limit > 0 && len(o.buf)-start-1 > limit {
+		raw := o.buf[start+1:]
+		n := limit
+		for n > 0 && !utf8.RuneStart(raw[n]) {
+			n--
+		}
+		cut = len(raw) - n + dropped
+		o.buf = o.buf[:start+1+n]
+	}
+
+	if o.fixed {
+		// closeString escapes the string into the free space behind it before moving it into place
+		n := len(`",`)
+		if raw := o.buf[start+1:]; needsEscaping(raw) {
+			n += escapedLen(raw)
+		}
+		if cut > 0 {
+			n += escapedLen(o.truncateSuffix) + maxIntLen
+		}
+		if !o.reserve(n) {
+			return
+		}
+	}
+
+	o.buf = closeString(o.buf, start)
+	if cut > 0 {
+		// The suffix goes before the closing quote
+		o.buf = appendTruncateSuffix(o.buf[:len(o.buf)-1], o.truncateSuffix, cut)
+		o.buf = append(o.buf, '"')
+	}
+	o.buf = append(o.buf, ',')
+}
+
+// Upper bounds of the size of encoded values, used to check that they fit in fixed-capacity mode.
+const (
+	maxIntLen      = len("-9223372036854775808")
+	maxDurationLen = len(`"-2562047h47m16.854775808s"`)
+	maxAddrLen     = len(`"[ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff%]:65535"`) // excluding the zone
+)
+
+// escapedLen returns the length of s once it is escaped by safeAppendString.
+func escapedLen[S []byte | string](s S) int {
+	n := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRuneInString(string(s[i:min(i+utf8.UTFMax, len(s))]))
+			if r == utf8.RuneError && size == 1 {
+				n += utf8.RuneLen(utf8.RuneError)
+			} else {
+				n += size
+			}
+			i += size
+			continue
+		case c == '\\' || c == '"' || c == '\n' || c == '\r' || c == '\t':
+			n += 2
+		case c < 0x20:
+			n += len(`\u0000`)
+		default:
+			n++
+		}
+		i++
+	}
+	return n
+}
+
+// intLen returns the number of digits of v, including the sign.
+func intLen(v int64) int {
+	if v < 0 {
+		return uintLen(-uint64(v)) + 1
+	}
+	return uintLen(uint64(v))
+}
+
+// uintLen returns the number of digits of v.
+func uintLen(v uint64) int {
+	n := 1
+	for ; v >= 10; v /= 10 {
+		n++
+	}
+	return n
+}
+
+// stringLen returns an upper bound of the size of s once it is encoded as a string value.
+func (o *Object) stringLen(s string) int {
+	if o.maxStringSize > 0 && len(s) > o.maxStringSize {
+		return escapedLen(s[:o.maxStringSize]) + escapedLen(o.truncateSuffix) + maxIntLen + 2
+	}
+	return escapedLen(s) + 2
+}
+
+// floatLen returns an upper bound of the size of an encoded float. Without an exponent, the digits of
+// large numbers are followed by zeros and small numbers are preceded by them, about log10(|v|) of them.
+func floatLen(v float64) int {
+	_, exp := math.Frexp(v)
+	return abs(exp)*3/10 + len("-0.12345678901234567") + 4
+}
+
+// complexLen returns an upper bound of the size of an encoded complex number in any representation.
+func complexLen(c complex128) int {
+	return floatLen(real(c)) + floatLen(imag(c)) + len(`{"re":,"im":}`)
+}
+
+// decimalLen returns an upper bound of the size of a decimal with the given scale.
+func (o *Object) decimalLen(scale int) int {
+	return maxIntLen + len(`"0."`) + abs(scale) + o.decimalFormat.MinFractionDigits + 1
+}
+
+// timeLen returns an upper bound of the size of t formatted with layout, including the quotes.
+// No element of a layout is written more than four times as long as it is, "2006" with a large year,
+// and only twice as long if the year has four digits, "1" for October. The only exception is the
+// name of the time zone.
+func timeLen(t time.Time, layout string) int {
+	n := 4 * len(layout)
+	if y := t.Year(); y >= 0 && y <= 9999 {
+		n = 2 * len(layout)
+		if layout == time.RFC3339 || layout == time.RFC3339Nano {
+			n = len(layout) // every element is zero padded
+		}
+	}
+	if strings.Contains(layout, "MST") {
+		name, _ := t.Zone()
+		n += strings.Count(layout, "MST") * len(name)
+	}
+	return n + 2
+}
+
+// urlLen returns an upper bound of the size of u encoded as a string value. Every part is escaped at
+// most once, which at worst triples it.
+func urlLen(u *url.URL) int {
+	n := len(u.Scheme) + len(u.Opaque) + len(u.Host) + len(u.Path) + len(u.RawPath) +
+		len(u.RawQuery) + len(u.Fragment) + len(u.RawFragment)
+	if u.User != nil {
+		password, _ := u.User.Password()
+		n += len(u.User.Username()) + len(password)
+	}
+	return 3*n + len(`"://:@./?#",`)
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// stringOf returns value.String(), or an error if it panics.
+func stringOf(value fmt.Stringer) (s string, err error) {
+	defer recoverPanic(&err, "String")
+	return value.String(), nil
+}
+
+// marshalOf calls value.MarshalFSON(o) and returns its error, perr is set if it panics.
+func marshalOf(value ObjectMarshaler, o *Object) (err, perr error) {
+	defer recoverPanic(&perr, "MarshalFSON")
+	return value.MarshalFSON(o), nil
+}
+
+// errorOf returns err.Error(), or an error if it panics.
+func errorOf(err error) (msg string, perr error) {
+	defer recoverPanic(&perr, "Error")
+	return err.Error(), nil
+}
+
+// recoverPanic is deferred around calls to methods like String and Error, which panic when they are
+// called on a nil pointer that doesn't expect it. The panic is recovered and stored in errp.
+func recoverPanic(errp *error, method string) {
+	if r := recover(); r != nil {
+		*errp = fmt.Errorf("fson: %s method panicked: %v", method, r)
+	}
+}
+
+// closeString finishes a JSON string whose opening quote is at buf[start] and whose unescaped
+// contents were appended directly behind it, for example by an AppendTo style method.
+//
+// The contents are only escaped if they turn out to contain characters that need escaping. In that
+// case the escaped form is appended behind the raw text and moved into place, so no intermediate
+// buffer is needed.
+func closeString(buf []byte, start int) []byte {
+	raw := buf[start+1:]
+	if !needsEscaping(raw) {
+		return append(buf, '"')
+	}
+
+	end := len(buf)
+	buf = safeAppendString(utf8.DecodeRune, buf, raw)
+	n := copy(buf[start+1:], buf[end:])
+	buf = buf[:start+1+n]
+	return append(buf, '"')
+}
+
+// appendUUID appends the quoted canonical 8-4-4-4-12 form of the UUID u.
+func appendUUID(buf []byte, u [16]byte) []byte {
+	var b [38]byte
+	b[0] = '"'
+	n := 1
+	for i, c := range u {
+		if i == 4 || i == 6 || i == 8 || i == 10 {
+			b[n] = '-'
+			n++
+		}
+		b[n] = _hex[c>>4]
+		b[n+1] = _hex[c&0xF]
+		n += 2
+	}
+	b[n] = '"'
+	return append(buf, b[:]...)
+}
+
+// needsEscaping reports whether safeAppendString would change s.
+func needsEscaping(s []byte) bool {
+	for _, c := range s {
+		if c < 0x20 || c == '\\' || c == '"' {
+			return true
+		}
+	}
+	// Invalid UTF-8 sequences get replaced by utf8.RuneError
+	return !utf8.Valid(s)
+}
+
 // The hex characters.
 const _hex = "0123456789abcdef"
 
@@ -1168,6 +4768,133 @@ func appendTime(buf []byte, t time.Time, format string) []byte {
 	return append(buf, '"')
 }
 
+// appendTimeRFC3339 appends t as a quoted time.RFC3339 or, if nano is set, time.RFC3339Nano string.
+//
+// The layout is fixed, so instead of going through t.AppendFormat the date is computed from the
+// Unix time with unsigned arithmetic only and the digits are written out two at a time. Years
+// outside the range [0, 9999] cannot be represented with four digits and are handed to
+// t.AppendFormat to match its output exactly.
+func appendTimeRFC3339(buf []byte, t time.Time, nano bool) []byte {
+	_, offset := t.Zone()
+	secs := t.Unix() + int64(offset) - minRFC3339Unix
+	if secs < 0 || secs > maxRFC3339Unix-minRFC3339Unix {
+		if nano {
+			return appendTime(buf, t, time.RFC3339Nano)
+		}
+		return appendTime(buf, t, time.RFC3339)
+	}
+
+	days := uint32(uint64(secs) / secondsPerDay)
+	clock := uint32(uint64(secs) - uint64(days)*secondsPerDay)
+	year, month, day := civilDate(days)
+
+	// "2006-01-02T15:04:05.999999999+07:00" fits in 35 bytes, the quotes are added separately
+	var b [35]byte
+	putDigitPair(b[0:2], year/100)
+	putDigitPair(b[2:4], year%100)
+	b[4] = '-'
+	putDigitPair(b[5:7], month)
+	b[7] = '-'
+	putDigitPair(b[8:10], day)
+	b[10] = 'T'
+	putDigitPair(b[11:13], clock/3600)
+	b[13] = ':'
+	putDigitPair(b[14:16], clock/60%60)
+	b[16] = ':'
+	putDigitPair(b[17:19], clock%60)
+	n := 19
+
+	if ns := uint32(t.Nanosecond()); nano && ns != 0 {
+		// Write all nine digits and trim the trailing zeros afterwards
+		b[19] = '.'
+		b[20] = byte('0' + ns/1e8)
+		ns %= 1e8
+		putDigitPair(b[21:23], ns/1e6)
+		putDigitPair(b[23:25], ns/1e4%100)
+		putDigitPair(b[25:27], ns/100%100)
+		putDigitPair(b[27:29], ns%100)
+		n = 29
+		for b[n-1] == '0' {
+			n--
+		}
+	}
+
+	if offset == 0 {
+		b[n] = 'Z'
+		n++
+	} else {
+		// The offset is written as ±hh:mm, any remaining seconds are dropped just like time.RFC3339 does
+		zone := offset / 60
+		b[n] = '+'
+		if zone < 0 {
+			b[n] = '-'
+			zone = -zone
+		}
+		putDigitPair(b[n+1:n+3], uint32(zone/60%100))
+		b[n+3] = ':'
+		putDigitPair(b[n+4:n+6], uint32(zone%60))
+		n += 6
+	}
+
+	buf = append(buf, '"')
+	buf = append(buf, b[:n]...)
+	return append(buf, '"')
+}
+
+const (
+	secondsPerDay  = 24 * 60 * 60
+	minRFC3339Unix = -62167219200 // 0000-01-01T00:00:00Z
+	maxRFC3339Unix = 253402300799 // 9999-12-31T23:59:59Z
+)
+
+// civilDate converts a number of days since 0000-01-01 into a proleptic Gregorian calendar date.
+//
+// This is the algorithm by Cassio Neri and Lorenz Schneider, "Euclidean affine functions and their
+// application to calendar algorithms" (2022), which only needs multiplications and shifts.
+func civilDate(days uint32) (year, month, day uint32) {
+	// Count from 0000-03-01 so that leap days end the year, shifted by one 400-year era to stay
+	// positive for January and February of year 0.
+	n := days + 146097 - 60
+
+	n1 := 4*n + 3
+	century := n1 / 146097
+	dayOfCentury := n1 % 146097 / 4
+
+	n2 := 4*dayOfCentury + 3
+	p2 := 2939745 * uint64(n2)
+	yearOfCentury := uint32(p2 >> 32)
+	dayOfYear := uint32(p2) / 2939745 / 4 // starting at March 1st
+
+	n3 := 2141*dayOfYear + 197913
+	month = n3 >> 16
+	day = n3&0xffff/2141 + 1
+	year = 100*century + yearOfCentury - 400
+	if dayOfYear >= 306 { // January and February belong to the next year
+		year++
+		month -= 12
+	}
+	return year, month, day
+}
+
+// _digitPairs holds the two-digit decimal representations of 0 through 99.
+const _digitPairs = "00010203040506070809" +
+	"10111213141516171819" +
+	"20212223242526272829" +
+	"30313233343536373839" +
+	"40414243444546474849" +
+	"50515253545556575859" +
+	"60616263646566676869" +
+	"70717273747576777879" +
+	"80818283848586878889" +
+	"90919293949596979899"
+
+// putDigitPair writes v, which is less than 100, as two decimal digits into dst.
+func putDigitPair(dst []byte, v uint32) {
+	_ = dst[1]
+	dst[0] = _digitPairs[2*v]
+	dst[1] = _digitPairs[2*v+1]
+}
+
 // appendFloat appends the provided float to the provided buffer.
 func appendFloat(buff []byte, val float64, bitSize int) []byte {
 	switch {
@@ -1182,6 +4909,129 @@ func appendFloat(buff []byte, val float64, bitSize int) []byte {
 	}
 }
 
+// appendDecimal appends the decimal unscaled * 10^-scale in the given representation.
+func appendDecimal(buf []byte, unscaled int64, scale int, format DecimalFormat) []byte {
+	if format.String {
+		buf = append(buf, '"')
+	}
+
+	abs := uint64(unscaled)
+	if unscaled < 0 {
+		buf = append(buf, '-')
+		abs = -abs // also correct for math.MinInt64
+	}
+
+	var tmp [20]byte
+	digits := strconv.AppendUint(tmp[:0], abs, 10)
+
+	// The fraction is made up of a number of leading zeros followed by the frac digits,
+	// e.g. 5 with scale 3 is 0.005
+	var frac []byte
+	leading := 0
+	switch {
+	case scale <= 0:
+		buf = append(buf, digits...)
+		if abs != 0 {
+			for range -scale {
+				buf = append(buf, '0')
+			}
+		}
+	case len(digits) > scale:
+		buf = append(buf, digits[:len(digits)-scale]...)
+		frac = digits[len(digits)-scale:]
+	default:
+		buf = append(buf, '0')
+		frac = digits
+		leading = scale - len(digits)
+	}
+
+	// Trailing zeros are only written as far as they are needed to reach the minimum
+	for len(frac) > 0 && frac[len(frac)-1] == '0' && leading+len(frac) > format.MinFractionDigits {
+		frac = frac[:len(frac)-1]
+	}
+	if len(frac) == 0 {
+		leading = 0
+	}
+
+	if written := leading + len(frac); written > 0 || format.MinFractionDigits > 0 {
+		buf = append(buf, '.')
+		for range leading {
+			buf = append(buf, '0')
+		}
+		buf = append(buf, frac...)
+		for range format.MinFractionDigits - written {
+			buf = append(buf, '0')
+		}
+	}
+
+	if format.String {
+		buf = append(buf, '"')
+	}
+	return buf
+}
+
+// appendComplex appends the complex number c in the given representation.
+// The real and imaginary parts are formatted with the given bitSize.
+func appendComplex(buf []byte, c complex128, bitSize int, format ComplexFormat) []byte {
+	re, im := real(c), imag(c)
+
+	switch format {
+	case ComplexArray:
+		buf = append(buf, '[')
+		buf = appendFloat(buf, re, bitSize)
+		buf = append(buf, ',')
+		buf = appendFloat(buf, im, bitSize)
+		return append(buf, ']')
+	case ComplexString:
+		// Special values can be written as-is since the whole number is a string,
+		// NaN and Infinity in the imaginary part still need an explicit sign like strconv.FormatComplex does
+		buf = append(buf, '"')
+		buf = strconv.AppendFloat(buf, re, 'f', -1, bitSize)
+		if math.IsNaN(im) || (!math.Signbit(im) && !math.IsInf(im, 1)) {
+			buf = append(buf, '+')
+		}
+		buf = strconv.AppendFloat(buf, im, 'f', -1, bitSize)
+		return append(buf, 'i', '"')
+	default:
+		buf = append(buf, `{"re":`...)
+		buf = appendFloat(buf, re, bitSize)
+		buf = append(buf, `,"im":`...)
+		buf = appendFloat(buf, im, bitSize)
+		return append(buf, '}')
+	}
+}
+
+// appendArrayValue appends vals as an array value. In fixed-capacity mode every element is only appended
+// after checking that an upper bound of its size, as returned by size, fits.
+func appendArrayValue[T any](o *Object, vals []T, size func(T) int, appendFn func([]byte, T) []byte) *Object {
+	if !o.fixed {
+		o.buf = appendArray(o.buf, vals, appendFn)
+		o.buf = append(o.buf, ',')
+		return o
+	}
+
+	if !o.reserve(len("[],")) {
+		return o
+	}
+	o.buf = append(o.buf, '[')
+	for i, val := range vals {
+		// Reserve the closing bracket and the comma that ends the value as well, but only once
+		n := size(val) + 2
+		if i > 0 {
+			n++
+		}
+		if !o.reserve(n) {
+			return o
+		}
+		if i > 0 {
+			o.buf = append(o.buf, ',')
+		}
+		o.buf = appendFn(o.buf, val)
+	}
+	o.buf = append(o.buf, ']', ',')
+	return o
+}
+
 // appendArray appends an array of provided elements of type T.
 func appendArray[T any](buf []byte, vals []T, appendFn func([]byte, T) []byte) []byte {
 	// If the array is empty, return the empty array marker
@@ -2,12 +2,22 @@ package fson_test
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/LucasRouckhout/fson"
 	"github.com/LucasRouckhout/fson/fsonutil"
 	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 	"unicode/utf8"
 )
@@ -532,6 +542,1893 @@ func TestObject_Reset(t *testing.T) {
 	}
 }
 
+func TestObject_TimeRFC3339(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	times := []time.Time{
+		time.Date(2025, 4, 1, 12, 30, 45, 0, time.UTC),
+		time.Date(2025, 4, 1, 12, 30, 45, 123456789, time.UTC),
+		time.Date(2025, 4, 1, 12, 30, 45, 120000000, time.FixedZone("CEST", 2*60*60)),
+		time.Date(1999, 12, 31, 23, 59, 59, 1, time.FixedZone("NST", -(3*60*60+30*60))),
+		time.Date(1, 1, 1, 0, 0, 0, 0, time.FixedZone("LMT", 17*60+30)),
+		time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(-1, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(0, 2, 29, 23, 59, 59, 999999999, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 0, time.FixedZone("", -60*60)),
+		time.Unix(0, 0),
+	}
+
+	obj := fson.NewObject(buf.Bytes())
+	for _, tm := range times {
+		obj.Reset()
+		got := string(obj.TimeRFC3339("t", tm).TimeRFC3339Nano("n", tm).Build())
+		want := `{"t":"` + tm.Format(time.RFC3339) + `","n":"` + tm.Format(time.RFC3339Nano) + `"}`
+		if got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+
+	// Every date in the range the dedicated formatter handles
+	for tm := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC); tm.Year() < 10000; tm = tm.Add(13*24*time.Hour + 1) {
+		obj.Reset()
+		got := string(obj.TimeRFC3339Nano("n", tm).Build())
+		if want := `{"n":"` + tm.Format(time.RFC3339Nano) + `"}`; got != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+	}
+
+	obj.Reset()
+	b := obj.TimesRFC3339("t", times).TimesRFC3339Nano("n", times).TimesRFC3339("empty", nil).Build()
+	if !json.Valid(b) {
+		t.Errorf("invalid json: %s", b)
+	}
+}
+
+func TestObject_NormalizeUTC(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	tm := time.Date(2025, 4, 1, 14, 30, 45, 500000000, time.FixedZone("CEST", 2*60*60))
+
+	obj := fson.NewObject(buf.Bytes()).NormalizeUTC(true)
+	got := string(obj.TimeRFC3339Nano("t", tm).Build())
+	if got != `{"t":"2025-04-01T12:30:45.5Z"}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+
+	// The setting survives a Reset
+	obj.Reset()
+	got = string(obj.TimesRFC3339("t", []time.Time{tm}).Build())
+	if got != `{"t":["2025-04-01T12:30:45Z"]}` {
+		t.Errorf("unexpected JSON after reset: %s", got)
+	}
+}
+
+// textOnly implements encoding.TextMarshaler but not encoding.TextAppender
+type textOnly struct {
+	text string
+	err  error
+}
+
+func (t textOnly) MarshalText() ([]byte, error) { return []byte(t.text), t.err }
+
+// textAppender implements both encoding.TextMarshaler and encoding.TextAppender
+type textAppender struct {
+	text string
+	err  error
+}
+
+func (t textAppender) MarshalText() ([]byte, error) { return t.AppendText(nil) }
+
+func (t textAppender) AppendText(b []byte) ([]byte, error) {
+	if t.err != nil {
+		return append(b, "partial"...), t.err
+	}
+	return append(b, t.text...), nil
+}
+
+func TestObject_StringerTextError(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	obj := fson.NewObject(buf.Bytes())
+	got := string(obj.
+		Stringer("month", time.April).
+		Stringer("nilStringer", nil).
+		Text("text", textOnly{text: "plain"}).
+		Text("appended", textAppender{text: "fast"}).
+		Text("escaped", textAppender{text: "with \"quotes\" and \n"}).
+		Text("invalid", textAppender{text: "bad \xff byte"}).
+		Text("nilText", nil).
+		Error("error", fmt.Errorf("boom")).
+		Error("nilError", nil).
+		Build())
+
+	want := `{"month":"April","nilStringer":null,"text":"plain","appended":"fast",` +
+		`"escaped":"with \"quotes\" and \n","invalid":"bad ` + "\ufffd" + ` byte","nilText":null,"error":"boom","nilError":null}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if obj.Err() != nil {
+		t.Errorf("unexpected error: %v", obj.Err())
+	}
+}
+
+func TestObject_TextError(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	first := fmt.Errorf("first")
+	obj := fson.NewObject(buf.Bytes())
+	got := string(obj.
+		Text("marshal", textOnly{err: first}).
+		Text("append", textAppender{err: fmt.Errorf("second")}).
+		Build())
+
+	if got != `{"marshal":null,"append":null}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+	if obj.Err() != first {
+		t.Errorf("expected the first error to be recorded, got %v", obj.Err())
+	}
+
+	obj.Reset()
+	if obj.Err() != nil {
+		t.Errorf("expected Reset to clear the error, got %v", obj.Err())
+	}
+
+	// time.Time returns a nil slice when it fails, even into a buffer without spare capacity
+	obj = fson.NewObject(nil)
+	got = string(obj.Text("time", time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)).Build())
+	if got != `{"time":null}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+	if obj.Err() == nil {
+		t.Errorf("expected an error to be recorded")
+	}
+}
+
+// statusError is an error that adds its own members to ErrorDetail
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string { return fmt.Sprintf("status %d", e.status) }
+
+func (e *statusError) Unwrap() error { return e.err }
+
+func (e *statusError) MarshalFSON(o *fson.Object) error {
+	o.Int("status", e.status)
+	return nil
+}
+
+// cyclicError wraps itself
+type cyclicError struct{}
+
+func (e *cyclicError) Error() string { return "cycle" }
+
+func (e *cyclicError) Unwrap() error { return e }
+
+// fanOutError joins itself n times
+type fanOutError struct{ n int }
+
+func (e *fanOutError) Error() string { return "fan-out" }
+
+func (e *fanOutError) Unwrap() []error {
+	errs := make([]error, e.n)
+	for i := range errs {
+		errs[i] = e
+	}
+	return errs
+}
+
+func TestObject_ErrorDetail(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	err := fmt.Errorf("request failed: %w", errors.Join(
+		&statusError{status: 503, err: errors.New("unavailable")},
+		errors.New("retry limit"),
+	))
+
+	got := string(fson.NewObject(buf.Bytes()).
+		ErrorDetail("error", err).
+		ErrorDetail("nil", nil).
+		Build())
+
+	want := `{"error":{"message":"request failed: status 503\nretry limit","causes":[` +
+		`{"message":"status 503\nretry limit","causes":[` +
+		`{"message":"status 503","status":503,"causes":[{"message":"unavailable"}]},` +
+		`{"message":"retry limit"}]}]},"nil":null}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestObject_ErrorDetailCycle(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	b := fson.NewObject(buf.Bytes()).ErrorDetail("error", &cyclicError{}).Build()
+	if !json.Valid(b) {
+		t.Fatalf("invalid json: %s", b)
+	}
+
+	// Walk down the causes and count the levels
+	var parsed map[string]any
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+	depth := 1
+	current := parsed["error"].(map[string]any)
+	for current["causes"] != nil {
+		current = current["causes"].([]any)[0].(map[string]any)
+		depth++
+	}
+	if depth != fson.MaxErrorDepth {
+		t.Errorf("expected %d levels, got %d", fson.MaxErrorDepth, depth)
+	}
+	if current["truncated"] != true {
+		t.Errorf("expected the deepest error to be marked as truncated: %v", current)
+	}
+
+	// Errors that fan out are limited in total, not only in depth
+	b = fson.NewObject(buf.Bytes()).ErrorDetail("error", &fanOutError{n: 3}).Build()
+	if !json.Valid(b) {
+		t.Fatalf("invalid json: %s", b)
+	}
+	if n := bytes.Count(b, []byte(`"message"`)); n != fson.MaxErrorCauses {
+		t.Errorf("expected %d errors, got %d", fson.MaxErrorCauses, n)
+	}
+	if !bytes.Contains(b, []byte(`"truncated":true`)) {
+		t.Errorf("expected a truncation marker: %s", b)
+	}
+}
+
+func TestObject_Stack(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	pcs := make([]uintptr, 8)
+	n := runtime.Callers(1, pcs)
+
+	b := fson.NewObject(buf.Bytes()).
+		Stack("stack", 0).
+		Frames("frames", pcs[:n]).
+		Frames("empty", nil).
+		Build()
+
+	var parsed struct {
+		Stack []struct {
+			Function string `json:"function"`
+			File     string `json:"file"`
+			Line     int    `json:"line"`
+		} `json:"stack"`
+		Frames []map[string]any `json:"frames"`
+		Empty  []any            `json:"empty"`
+	}
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatalf("failed to unmarshal JSON %s: %v", b, err)
+	}
+
+	if len(parsed.Stack) == 0 {
+		t.Fatalf("expected at least one frame: %s", b)
+	}
+	if top := parsed.Stack[0]; top.Function != "github.com/LucasRouckhout/fson_test.TestObject_Stack" || top.Line == 0 {
+		t.Errorf("expected the first frame to be the caller of Stack, got %+v", top)
+	}
+	if len(parsed.Frames) != n {
+		t.Errorf("expected %d frames, got %d", n, len(parsed.Frames))
+	}
+	if parsed.Empty == nil || len(parsed.Empty) != 0 {
+		t.Errorf("expected an empty array, got %v", parsed.Empty)
+	}
+}
+
+func TestObject_NetworkTypes(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	u, err := url.Parse("https://user@example.com/a b?q=\"fson\"#frag")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := fson.NewObject(buf.Bytes())
+	got := string(obj.
+		Addr("v4", netip.MustParseAddr("192.168.0.1")).
+		Addr("v6", netip.MustParseAddr("fe80::1%eth\"0")).
+		Addr("zeroAddr", netip.Addr{}).
+		AddrPort("addrPort", netip.MustParseAddrPort("[::1]:8080")).
+		Prefix("prefix", netip.MustParsePrefix("10.0.0.0/8")).
+		IP("ip", net.IPv4(10, 0, 0, 1)).
+		IP("ip6", net.ParseIP("2001:db8::1")).
+		IP("nilIP", nil).
+		URL("url", u).
+		URL("nilURL", nil).
+		UUID("uuid", [16]byte{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}).
+		Build())
+
+	want := `{"v4":"192.168.0.1","v6":"fe80::1%eth\"0","zeroAddr":"","addrPort":"[::1]:8080","prefix":"10.0.0.0/8",` +
+		`"ip":"10.0.0.1","ip6":"2001:db8::1","nilIP":null,"url":"https://user@example.com/a%20b?q=\"fson\"#frag",` +
+		`"nilURL":null,"uuid":"6ba7b810-9dad-11d1-80b4-00c04fd430c8"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if obj.Err() != nil {
+		t.Errorf("unexpected error: %v", obj.Err())
+	}
+
+	obj.Reset()
+	got = string(obj.IP("invalid", net.IP{1, 2, 3}).Build())
+	if got != `{"invalid":null}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+	if obj.Err() == nil {
+		t.Errorf("expected an error for an invalid IP")
+	}
+}
+
+func TestObject_Complex(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	values := []complex128{1 + 2i, -1.5 - 0.25i, complex(math.NaN(), math.Inf(1)), complex(0, math.Inf(-1)), complex(3, math.NaN())}
+
+	tests := []struct {
+		format fson.ComplexFormat
+		want   string
+	}{
+		{fson.ComplexObject, `{"z":{"re":1,"im":2},"zs":[{"re":1,"im":2},{"re":-1.5,"im":-0.25},{"re":"NaN","im":"+Inf"},` +
+			`{"re":0,"im":"-Inf"},{"re":3,"im":"NaN"}],"z64":{"re":0.1,"im":0}}`},
+		{fson.ComplexArray, `{"z":[1,2],"zs":[[1,2],[-1.5,-0.25],["NaN","+Inf"],[0,"-Inf"],[3,"NaN"]],"z64":[0.1,0]}`},
+		{fson.ComplexString, `{"z":"1+2i","zs":["1+2i","-1.5-0.25i","NaN+Infi","0-Infi","3+NaNi"],"z64":"0.1+0i"}`},
+	}
+
+	obj := fson.NewObject(buf.Bytes())
+	for _, tt := range tests {
+		obj.Reset()
+		got := string(obj.EncodeComplexAs(tt.format).
+			Complex128("z", values[0]).
+			Complexes128("zs", values).
+			Complex64("z64", 0.1).
+			Build())
+		if got != tt.want {
+			t.Errorf("format %d: expected %s, got %s", tt.format, tt.want, got)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Errorf("format %d: invalid json: %s", tt.format, got)
+		}
+	}
+}
+
+func TestSeq(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	type score struct {
+		name   string
+		points int
+	}
+	scores := []score{{"alice", 10}, {"bob", 7}}
+
+	// Keys come from a typed string to check that ~string works
+	type name string
+	byName := func(yield func(name, int) bool) {
+		for _, s := range scores {
+			if !yield(name(s.name), s.points) {
+				return
+			}
+		}
+	}
+
+	obj := fson.NewObject(buf.Bytes())
+	fson.Seq(obj, "names", slices.Values(scores), func(o *fson.Object, s score) {
+		o.StringValue(s.name)
+	})
+	fson.Seq(obj, "empty", slices.Values([]int(nil)), func(o *fson.Object, i int) {
+		o.IntValue(i)
+	})
+	fson.Seq2(obj, "scores", byName, func(o *fson.Object, points int) {
+		o.IntValue(points)
+	})
+	obj.Array("nested")
+	fson.Seq2Value(obj, byName, func(o *fson.Object, points int) {
+		o.IntValue(points)
+	})
+	obj.EndArray()
+
+	got := string(obj.Build())
+	want := `{"names":["alice","bob"],"empty":[],"scores":{"alice":10,"bob":7},"nested":[{"alice":10,"bob":7}]}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestGenericNumbers(t *testing.T) {
+	// Not parallel, AllocsPerRun does not support it
+	type UserID int64
+	type Port uint16
+	type Celsius float32
+	type Ratio float64
+
+	buf := make([]byte, 0, 1024)
+	obj := fson.NewObject(buf)
+
+	build := func() []byte {
+		obj.Reset()
+		fson.Int(obj, "user", UserID(-42))
+		fson.Ints(obj, "users", []UserID{1, 2, 3})
+		fson.Uint(obj, "port", Port(8080))
+		fson.Uints(obj, "ports", []Port{80, 443})
+		fson.Float(obj, "temperature", Celsius(0.1))
+		fson.Floats(obj, "ratios", []Ratio{0.1, Ratio(math.Inf(1))})
+		obj.Array("values")
+		fson.IntValue(obj, int8(-8))
+		fson.UintValue(obj, uint8(8))
+		fson.FloatValue(obj, 1.5)
+		fson.IntsValue(obj, []int{})
+		fson.UintsValue(obj, []uint64{math.MaxUint64})
+		fson.FloatsValue(obj, []float32{0.1})
+		obj.EndArray()
+		return obj.Build()
+	}
+
+	got := string(build())
+	want := `{"user":-42,"users":[1,2,3],"port":8080,"ports":[80,443],"temperature":0.1,"ratios":[0.1,"+Inf"],` +
+		`"values":[-8,8,1.5,[],[18446744073709551615],[0.1]]}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { build() }); allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestObject_Decimal(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	tests := []struct {
+		unscaled int64
+		scale    int
+		format   fson.DecimalFormat
+		want     string
+	}{
+		{12345, 2, fson.DecimalFormat{}, "123.45"},
+		{-12345, 2, fson.DecimalFormat{}, "-123.45"},
+		{12300, 2, fson.DecimalFormat{}, "123"},
+		{12340, 2, fson.DecimalFormat{}, "123.4"},
+		{12300, 2, fson.DecimalFormat{MinFractionDigits: 2}, "123.00"},
+		{12345, 2, fson.DecimalFormat{MinFractionDigits: 4}, "123.4500"},
+		{5, 3, fson.DecimalFormat{}, "0.005"},
+		{-5, 3, fson.DecimalFormat{}, "-0.005"},
+		{500, 3, fson.DecimalFormat{}, "0.5"},
+		{0, 2, fson.DecimalFormat{}, "0"},
+		{0, 2, fson.DecimalFormat{MinFractionDigits: 2}, "0.00"},
+		{123, 0, fson.DecimalFormat{}, "123"},
+		{123, 0, fson.DecimalFormat{MinFractionDigits: 1}, "123.0"},
+		{123, -2, fson.DecimalFormat{}, "12300"},
+		{0, -2, fson.DecimalFormat{}, "0"},
+		{math.MinInt64, 18, fson.DecimalFormat{}, "-9.223372036854775808"},
+		{math.MaxInt64, 25, fson.DecimalFormat{}, "0.0000009223372036854775807"},
+		{12345, 2, fson.DecimalFormat{String: true}, `"123.45"`},
+		{-100, 2, fson.DecimalFormat{MinFractionDigits: 2, String: true}, `"-1.00"`},
+	}
+
+	obj := fson.NewObject(buf.Bytes())
+	for _, tt := range tests {
+		obj.Reset()
+		got := string(obj.EncodeDecimalsAs(tt.format).Decimal("d", tt.unscaled, tt.scale).Build())
+		want := `{"d":` + tt.want + `}`
+		if got != want {
+			t.Errorf("Decimal(%d, %d, %+v): expected %s, got %s", tt.unscaled, tt.scale, tt.format, want, got)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Errorf("invalid json: %s", got)
+		}
+	}
+
+	obj.Reset()
+	got := string(obj.EncodeDecimalsAs(fson.DecimalFormat{}).Decimals("amounts", []int64{12345, -50}, 2).Decimals("empty", nil, 2).Build())
+	if got != `{"amounts":[123.45,-0.5],"empty":[]}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+
+	// Absurd scales are rejected instead of writing endless zeros
+	obj.Reset()
+	got = string(obj.Decimal("max", 1, -fson.MaxDecimalScale).Decimal("big", 1, 1e9).Decimals("small", []int64{1}, -1e9).Build())
+	if want := `{"max":1` + strings.Repeat("0", fson.MaxDecimalScale) + `,"big":null,"small":null}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if !errors.Is(obj.Err(), fson.ErrDecimalScale) {
+		t.Errorf("expected ErrDecimalScale, got %v", obj.Err())
+	}
+}
+
+// point implements fson.ObjectMarshaler
+type point struct{ x, y int }
+
+func (p point) MarshalFSON(o *fson.Object) error {
+	o.Int("x", p.x).Int("y", p.y)
+	return nil
+}
+
+func TestObject_Any(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	tm := time.Date(2025, 4, 1, 12, 0, 0, 500, time.UTC)
+
+	obj := fson.NewObject(buf.Bytes())
+	got := string(obj.
+		Any("nil", nil).
+		Any("bool", true).
+		Any("string", "s").
+		Any("int", -1).
+		Any("int8", int8(-8)).
+		Any("uint16", uint16(16)).
+		Any("uintptr", uintptr(1)).
+		Any("float32", float32(0.1)).
+		Any("complex", 1+2i).
+		Any("time", tm).
+		Any("duration", time.Second).
+		Any("bytes", []byte{1, 2}).
+		Any("floats", []float64{1.5}).
+		Any("times", []time.Time{tm}).
+		Any("slice", []any{1, "two", []any{}, map[string]any{"k": nil}}).
+		Any("map", map[string]any{"nested": map[string]any{"n": 1}}).
+		Any("marshaler", point{1, 2}).
+		Any("error", errors.New("boom")).
+		Any("text", netip.MustParseAddr("::1")).
+		Any("stringer", time.April).
+		Build())
+
+	want := `{"nil":null,"bool":true,"string":"s","int":-1,"int8":-8,"uint16":16,"uintptr":1,"float32":0.1,` +
+		`"complex":{"re":1,"im":2},"time":"2025-04-01T12:00:00.0000005Z","duration":"1s","bytes":[1,2],"floats":[1.5],` +
+		`"times":["2025-04-01T12:00:00.0000005Z"],"slice":[1,"two",[],{"k":null}],"map":{"nested":{"n":1}},` +
+		`"marshaler":{"x":1,"y":2},"error":"boom","text":"::1","stringer":"April"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if obj.Err() != nil {
+		t.Errorf("unexpected error: %v", obj.Err())
+	}
+}
+
+// nilStringer panics in its methods when called on a nil pointer
+type nilStringer struct{ name string }
+
+func (s *nilStringer) String() string { return s.name }
+
+func (s *nilStringer) Error() string { return s.name }
+
+func (s *nilStringer) MarshalText() ([]byte, error) { return []byte(s.name), nil }
+
+func (s *nilStringer) MarshalFSON(o *fson.Object) error {
+	o.Object("nested")
+	o.String("name", s.name)
+	return nil
+}
+
+func TestObject_AnyCycles(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	m := map[string]any{"a": 1}
+	m["self"] = m
+	m["again"] = m
+	l := []any{1, nil}
+	l[1] = l
+
+	deep := any("bottom")
+	for range fson.MaxAnyDepth + 5 {
+		deep = []any{deep}
+	}
+
+	obj := fson.NewObject(buf.Bytes())
+	b := obj.Any("m", m).Any("l", l).Any("empty", []any{[]any{}, map[string]any{}}).Build()
+	var parsed map[string]any
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	if got := parsed["m"].(map[string]any)["self"]; got != fson.DefaultDepthPlaceholder {
+		t.Errorf("expected the cycle to be replaced, got %v", got)
+	}
+	if got := parsed["l"].([]any)[1]; got != fson.DefaultDepthPlaceholder {
+		t.Errorf("expected the cycle to be replaced, got %v", got)
+	}
+	if got := fmt.Sprint(parsed["empty"]); got != "[[] map[]]" {
+		t.Errorf("expected empty containers to be kept, got %v", got)
+	}
+	if !errors.Is(obj.Err(), fson.ErrMaxDepth) {
+		t.Errorf("expected ErrMaxDepth, got %v", obj.Err())
+	}
+
+	obj.Reset()
+	b = obj.Any("deep", deep).Build()
+	if n := bytes.Count(b, []byte("[")); n != fson.MaxAnyDepth {
+		t.Errorf("expected %d levels, got %d: %s", fson.MaxAnyDepth, n, b)
+	}
+	if !bytes.Contains(b, []byte(fson.DefaultDepthPlaceholder)) {
+		t.Errorf("expected a depth marker: %s", b)
+	}
+
+	// Typed nil pointers whose methods panic are encoded as null
+	var ns *nilStringer
+	obj.Reset()
+	got := string(obj.
+		Any("stringer", fmt.Stringer(ns)).
+		Any("error", error(ns)).
+		Stringer("s", ns).
+		Error("e", ns).
+		Text("t", ns).
+		ErrorDetail("d", ns).
+		Any("any", ns).
+		Marshaler("m", ns).
+		String("after", "ok").
+		Build())
+	want := `{"stringer":null,"error":null,"s":null,"e":null,"t":null,"d":null,"any":null,"m":null,"after":"ok"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if obj.Err() == nil {
+		t.Errorf("expected the panic to be recorded")
+	}
+}
+
+func TestObject_AnyFallback(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	type custom struct{ A int }
+
+	// Default fallback
+	obj := fson.NewObject(buf.Bytes())
+	got := string(obj.Any("custom", custom{1}).Build())
+	if got != `{"custom":null}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+	var unsupported *fson.UnsupportedTypeError
+	if !errors.As(obj.Err(), &unsupported) {
+		t.Errorf("expected an UnsupportedTypeError, got %v", obj.Err())
+	}
+
+	obj.Reset()
+	got = string(obj.AnyFallback(fson.FallbackSprint).Any("custom", custom{1}).Build())
+	if got != `{"custom":"{1}"}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+
+	obj.Reset()
+	got = string(obj.AnyFallback(func(o *fson.Object, v any) {
+		if c, ok := v.(custom); ok {
+			o.StartObject().Int("a", c.A).EndObject()
+			return
+		}
+		fson.FallbackError(o, v)
+	}).Any("custom", []custom{{1}}).Any("other", struct{}{}).Build())
+	if got != `{"custom":null,"other":null}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+
+	obj.Reset()
+	got = string(obj.Any("custom", custom{2}).Build())
+	if got != `{"custom":{"a":2}}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+}
+
+func TestObject_ObjectString(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	b := fson.NewObject(buf.Bytes()).
+		String("id", "1").
+		ObjectString("body").
+		String("quote", "say \"hi\"\n").
+		String("unicode", "😀").
+		Ints("ints", []int{1, 2}).
+		ObjectString("inner").
+		String("deep", "\\").
+		EndObjectString().
+		EndObjectString().
+		Array("list").
+		StartObjectString().EndObjectString().
+		EndArray().
+		Build()
+
+	if !json.Valid(b) {
+		t.Fatalf("invalid json: %s", b)
+	}
+
+	var outer struct {
+		Body string   `json:"body"`
+		List []string `json:"list"`
+	}
+	if err := json.Unmarshal(b, &outer); err != nil {
+		t.Fatalf("failed to unmarshal JSON %s: %v", b, err)
+	}
+	if len(outer.List) != 1 || outer.List[0] != "{}" {
+		t.Errorf("expected an embedded empty object, got %v", outer.List)
+	}
+
+	var body struct {
+		Quote   string `json:"quote"`
+		Unicode string `json:"unicode"`
+		Ints    []int  `json:"ints"`
+		Inner   string `json:"inner"`
+	}
+	if err := json.Unmarshal([]byte(outer.Body), &body); err != nil {
+		t.Fatalf("failed to unmarshal embedded JSON %s: %v", outer.Body, err)
+	}
+	if body.Quote != "say \"hi\"\n" || body.Unicode != "😀" || len(body.Ints) != 2 {
+		t.Errorf("unexpected embedded object: %+v", body)
+	}
+	if body.Inner != `{"deep":"\\"}` {
+		t.Errorf("unexpected doubly embedded object: %s", body.Inner)
+	}
+}
+
+func TestObject_StringWriter(t *testing.T) {
+	t.Parallel()
+
+	input := "plain \"quoted\" \\ \n\t\u0001 é 😀 \xff\xe2\x82 end \xf0\x9f\x98"
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	obj := fson.NewObject(buf.Bytes())
+	want := string(obj.String("s", input).Build())
+
+	// Write the input in chunks of every size to split the UTF-8 sequences in every possible way
+	for size := 1; size <= len(input); size++ {
+		obj.Reset()
+		w := obj.StringWriter("s")
+		for chunk := range slices.Chunk([]byte(input), size) {
+			if n, err := w.Write(chunk); n != len(chunk) || err != nil {
+				t.Fatalf("chunk size %d: unexpected write result %d, %v", size, n, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("unexpected close error: %v", err)
+		}
+
+		if got := string(obj.Build()); got != want {
+			t.Errorf("chunk size %d: expected %s, got %s", size, want, got)
+		}
+	}
+
+	w := obj.Key("closed").StringWriterValue()
+	_ = w.Close()
+	if _, err := w.Write([]byte("x")); !errors.Is(err, fson.ErrWriterClosed) {
+		t.Errorf("expected ErrWriterClosed, got %v", err)
+	}
+}
+
+func TestObject_StringWriterNoAllocs(t *testing.T) {
+	obj := fson.NewObject(make([]byte, 0, 1024))
+	chunks := [][]byte{[]byte("a\xf0\x9f"), []byte("\x98"), []byte("\x80b\xe2"), []byte("\xff")}
+
+	// Completing a UTF-8 sequence split across writes must not allocate
+	allocs := testing.AllocsPerRun(100, func() {
+		obj.Reset()
+		w := obj.StringWriter("s")
+		for _, chunk := range chunks {
+			_, _ = w.Write(chunk)
+		}
+		_ = w.Close()
+		result = obj.Build()
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+	if got, want := string(result), "{\"s\":\"a😀b\uFFFD\uFFFD\"}"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestObject_ReadStringFrom(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Repeat("line with \"quotes\" and 😀\n", 100)
+
+	// Start with a tiny buffer to force it to grow while reading
+	obj := fson.NewObject(make([]byte, 0, 8))
+	got := string(obj.
+		ReadStringFrom("body", iotest.HalfReader(strings.NewReader(input))).
+		ReadStringFrom("empty", strings.NewReader("")).
+		Build())
+
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("failed to unmarshal JSON %s: %v", got, err)
+	}
+	if parsed["body"] != input || parsed["empty"] != "" {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+
+	readErr := errors.New("read failed")
+	obj.Reset()
+	got = string(obj.ReadStringFrom("body", iotest.TimeoutReader(strings.NewReader("partial"))).Build())
+	if got != `{"body":"partial"}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+	if !errors.Is(obj.Err(), iotest.ErrTimeout) {
+		t.Errorf("expected the read error to be recorded, got %v", obj.Err())
+	}
+
+	obj.Reset()
+	got = string(obj.ReadStringFrom("body", iotest.ErrReader(readErr)).Build())
+	if got != `{"body":""}` || !errors.Is(obj.Err(), readErr) {
+		t.Errorf("unexpected result: %s, %v", got, obj.Err())
+	}
+}
+
+func TestObject_Base64Writer(t *testing.T) {
+	t.Parallel()
+
+	input := make([]byte, 100)
+	for i := range input {
+		input[i] = byte(i * 7)
+	}
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	encodings := []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding}
+
+	obj := fson.NewObject(buf.Bytes())
+	for _, enc := range encodings {
+		// Every input length combined with every chunk size covers all padding and block boundaries
+		for length := 0; length <= 10; length++ {
+			for size := 1; size <= 4; size++ {
+				obj.Reset()
+				w := obj.Base64Writer("data", enc)
+				for chunk := range slices.Chunk(input[:length], size) {
+					if n, err := w.Write(chunk); n != len(chunk) || err != nil {
+						t.Fatalf("unexpected write result %d, %v", n, err)
+					}
+				}
+				if err := w.Close(); err != nil {
+					t.Fatalf("unexpected close error: %v", err)
+				}
+
+				got := string(obj.Build())
+				want := `{"data":"` + enc.EncodeToString(input[:length]) + `"}`
+				if got != want {
+					t.Errorf("length %d, chunk size %d: expected %s, got %s", length, size, want, got)
+				}
+			}
+		}
+	}
+
+	obj.Reset()
+	w := obj.Base64Writer("data", base64.StdEncoding)
+	_, _ = w.Write(input)
+	_ = w.Close()
+	if _, err := w.Write(input); !errors.Is(err, fson.ErrWriterClosed) {
+		t.Errorf("expected ErrWriterClosed, got %v", err)
+	}
+	if got := string(obj.Build()); got != `{"data":"`+base64.StdEncoding.EncodeToString(input)+`"}` {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+}
+
+func TestObject_ContainerFuncs(t *testing.T) {
+	// Not parallel, AllocsPerRun does not support it
+	people := []string{"Alice", "Bob"}
+
+	obj := fson.NewObject(make([]byte, 0, 1024))
+	build := func() []byte {
+		obj.Reset()
+		return obj.
+			ObjectFunc("person", func(o *fson.Object) {
+				o.String("name", people[0])
+			}).
+			ArrayFunc("people", func(o *fson.Object) {
+				for _, p := range people {
+					o.StartObjectFunc(func(o *fson.Object) {
+						o.String("name", p)
+					})
+				}
+				o.StartArrayFunc(func(o *fson.Object) {})
+			}).
+			ObjectFunc("empty", func(o *fson.Object) {}).
+			Build()
+	}
+
+	got := string(build())
+	want := `{"person":{"name":"Alice"},"people":[{"name":"Alice"},{"name":"Bob"},[]],"empty":{}}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { build() }); allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestObject_Lazy(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	calls := 0
+	expensive := func(o *fson.Object) {
+		calls++
+		o.StringValue("computed")
+	}
+
+	obj := fson.NewObject(buf.Bytes())
+	got := string(obj.Lazy("always", expensive).Build())
+	if got != `{"always":"computed"}` || calls != 1 {
+		t.Errorf("unexpected result without filter: %s, %d calls", got, calls)
+	}
+
+	obj.Reset()
+	got = string(obj.
+		LazyFilter(func(key string) bool { return !strings.HasPrefix(key, "debug.") }).
+		String("msg", "hello").
+		Lazy("debug.dump", expensive).
+		Lazy("summary", expensive).
+		Lazy("debug.other", expensive).
+		Build())
+	if got != `{"msg":"hello","summary":"computed"}` || calls != 2 {
+		t.Errorf("unexpected result with filter: %s, %d calls", got, calls)
+	}
+
+	obj.Reset()
+	got = string(obj.Lazy("debug.dump", expensive).Build())
+	if got != `{}` || calls != 2 {
+		t.Errorf("expected the filter to survive a reset: %s, %d calls", got, calls)
+	}
+}
+
+// failingMarshaler writes a few members and then fails
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalFSON(o *fson.Object) error {
+	o.String("partial", "value").Array("list").IntValue(1)
+	return errors.New("marshal failed")
+}
+
+func TestObject_ContainersNoAllocs(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+
+	// Tracking open containers must not allocate, even for a fresh Object
+	allocs := testing.AllocsPerRun(100, func() {
+		obj := fson.NewObject(buf)
+		sp := obj.Savepoint()
+		obj.Object("a").Array("b").StartObjectString().Int("c", 1).EndObjectString().EndArray().EndObject()
+		obj.Rollback(sp).ObjectString("d").Object("e").EndObject().EndObjectString()
+		result = obj.Build()
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+	if got, want := string(result), `{"d":"{\"e\":{}}"}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestObject_Rollback(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	obj := fson.NewObject(buf.Bytes()).String("before", "x")
+
+	// Abandon a member whose marshaler fails halfway through
+	sp := obj.Savepoint()
+	obj.Marshaler("failing", failingMarshaler{})
+	if obj.Err() == nil {
+		t.Fatal("expected an error from the marshaler")
+	}
+	obj.Rollback(sp)
+	if obj.Err() != nil {
+		t.Errorf("expected the error to be rolled back, got %v", obj.Err())
+	}
+
+	// Abandon a nested object with unclosed containers and a dangling key
+	obj.Object("nested").String("a", "b")
+	sp = obj.Savepoint()
+	obj.Object("inner").Array("items").StartObject().Key("dangling")
+	obj.Rollback(sp)
+	obj.String("c", "d").EndObject()
+
+	// Roll back past the end of a container that was open at the savepoint
+	obj.Array("arr").IntValue(1)
+	sp = obj.Savepoint()
+	obj.IntValue(2).EndArray().String("gone", "x")
+	obj.Rollback(sp)
+	obj.IntValue(3).EndArray()
+
+	// Rollback also discards an unfinished string writer
+	sp = obj.Savepoint()
+	w := obj.StringWriter("stream")
+	_, _ = w.Write([]byte("data"))
+	obj.Rollback(sp)
+	if _, err := w.Write([]byte("more")); !errors.Is(err, fson.ErrWriterClosed) {
+		t.Errorf("expected ErrWriterClosed, got %v", err)
+	}
+	_ = w.Close()
+
+	// Merged members are rolled back like any other
+	sp = obj.Savepoint()
+	obj.MergeObject([]byte(`{"before":"y"}`))
+	obj.Rollback(sp)
+
+	got := string(obj.String("after", "y").Build())
+	want := `{"before":"x","nested":{"a":"b","c":"d"},"arr":[1,3],"after":"y"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestObject_RollbackOverwrittenContainer(t *testing.T) {
+	t.Parallel()
+
+	obj := fson.NewObject(make([]byte, 0, 64))
+	obj.Object("a")
+	sp := obj.Savepoint()
+	obj.EndObject().Object("b")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Rollback to panic")
+		}
+	}()
+	obj.Rollback(sp)
+}
+
+func TestObject_RollbackMaxSize(t *testing.T) {
+	t.Parallel()
+
+	// The member that was being written in each open container when the savepoint was taken is still
+	// dropped when it turns out to exceed the budget, also beyond the containers that are kept inline.
+	for _, depth := range []int{1, 6} {
+		obj := fson.NewObject(nil).MaxSize(60)
+		for range depth {
+			obj.Object("n")
+		}
+		obj.Key("a")
+		sp := obj.Savepoint()
+		obj.Object("x").EndObject().String("b", "1").Rollback(sp)
+		obj.StringValue(strings.Repeat("x", 80))
+		for range depth {
+			obj.EndObject()
+		}
+
+		got := string(obj.Build())
+		want := strings.Repeat(`{"n":`, depth) + `{}` + strings.Repeat(`}`, depth-1) + `,"_truncated":true}`
+		if got != want {
+			t.Errorf("depth %d: expected %s, got %s", depth, want, got)
+		}
+	}
+}
+
+func TestObject_RollbackRemovedMembers(t *testing.T) {
+	t.Parallel()
+
+	obj := fson.NewObject(make([]byte, 0, 64)).MergeDuplicates(fson.MergeKeepIncoming)
+	obj.String("a", "x")
+	sp := obj.Savepoint()
+	obj.String("b", "2").MergeObject([]byte(`{"a":"y"}`))
+
+	// Savepoints taken after the members were removed still work
+	after := obj.Savepoint()
+	obj.MergeObject([]byte(`{"c":3}`)).Rollback(after)
+	if got, want := string(obj.Build()), `{"b":"2","a":"y"}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Rollback to panic")
+		}
+	}()
+	obj.Rollback(sp)
+}
+
+// tree is a recursive structure used to produce deeply nested JSON
+type tree struct {
+	name     string
+	children []tree
+}
+
+func (t tree) MarshalFSON(o *fson.Object) error {
+	o.String("name", t.name).Array("children")
+	for _, child := range t.children {
+		o.MarshalerValue(child)
+	}
+	o.EndArray()
+	return nil
+}
+
+func TestObject_MaxDepth(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	deep := tree{"a", []tree{{"b", []tree{{"c", []tree{{"d", nil}}}}}}}
+
+	obj := fson.NewObject(buf.Bytes()).MaxDepth(4, fson.DefaultDepthPlaceholder)
+	got := string(obj.Marshaler("tree", deep).Build())
+	want := `{"tree":{"name":"a","children":[{"name":"b","children":"<max depth>"}]}}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if obj.Err() != nil {
+		t.Errorf("unexpected error: %v", obj.Err())
+	}
+
+	// Without a placeholder the subtree becomes null and an error is recorded
+	obj.Reset().MaxDepth(2, "")
+	got = string(obj.
+		Object("a").Array("b").IntValue(1).EndArray().EndObject().
+		Array("c").StartObjectString().EndObjectString().EndArray().
+		Object("d").ObjectString("e").EndObjectString().EndObject().
+		Build())
+	want = `{"a":{"b":null},"c":[null],"d":{"e":null}}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if !errors.Is(obj.Err(), fson.ErrMaxDepth) {
+		t.Errorf("expected ErrMaxDepth, got %v", obj.Err())
+	}
+
+	// Depth reports the current nesting
+	obj.Reset().MaxDepth(0, "")
+	if obj.Depth() != 1 {
+		t.Errorf("expected depth 1, got %d", obj.Depth())
+	}
+	obj.Object("a").Array("b")
+	if obj.Depth() != 3 {
+		t.Errorf("expected depth 3, got %d", obj.Depth())
+	}
+}
+
+func TestObject_MaxSize(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	obj := fson.NewObject(buf.Bytes()).MaxSize(32)
+	got := string(obj.String("a", "short").String("b", strings.Repeat("x", 64)).Int("c", 1).Build())
+	want := `{"a":"short","_truncated":true}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// Members of nested objects are dropped on their own, the object itself is kept
+	obj.Reset()
+	got = string(obj.
+		Object("outer").Int("x", 1).String("big", strings.Repeat("x", 64)).Int("y", 2).EndObject().
+		Array("arr").IntValue(1).EndArray().
+		Build())
+	want = `{"outer":{"x":1},"_truncated":true}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if !json.Valid([]byte(got)) {
+		t.Errorf("invalid JSON: %s", got)
+	}
+
+	// Documents within budget are unchanged and Build can be called twice
+	obj.Reset()
+	obj.Int("a", 1)
+	obj.Build()
+	got = string(obj.Build())
+	want = `{"a":1}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// Rolling back restores the truncation state
+	obj.Reset()
+	sp := obj.Savepoint()
+	obj.String("big", strings.Repeat("x", 64)).Int("a", 1)
+	obj.Rollback(sp)
+	got = string(obj.Int("b", 2).Build())
+	want = `{"b":2}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestObject_MaxStringSize(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	obj := fson.NewObject(buf.Bytes()).MaxStringSize(4, fson.DefaultTruncateSuffix)
+	got := string(obj.
+		String("msg", "hello world").
+		String("short", "hey").
+		String("utf8", "hé\u00e9llo").
+		Strings("list", []string{"abcdef", "ab"}).
+		Build())
+	want := `{"msg":"hell...(truncated 7 bytes)","short":"hey","utf8":"hé...(truncated 5 bytes)",` +
+		`"list":["abcd...(truncated 2 bytes)","ab"]}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// The suffix is escaped and doesn't need a count
+	obj.Reset().MaxStringSize(2, `"…`)
+	got = string(obj.String("a", "abc").Strings("b", nil).Build())
+	want = `{"a":"ab\"…","b":[]}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	// The limit applies to every path that produces a string
+	obj.Reset().MaxStringSize(4, fson.DefaultTruncateSuffix)
+	w := obj.StringWriter("writer")
+	for _, chunk := range []string{"ab", "c\xe2\x82", "\xacd", "ef"} {
+		_, _ = w.Write([]byte(chunk))
+	}
+	_ = w.Close()
+	got = string(obj.
+		ReadStringFrom("reader", iotest.OneByteReader(strings.NewReader(strings.Repeat("x", 1000)))).
+		Text("text", netip.MustParseAddr("192.168.0.1")).
+		ObjectString("embedded").Int("a", 1).EndObjectString().
+		Addr("addr", netip.MustParseAddr("10.0.0.1")).
+		Build())
+	want = `{"writer":"abc...(truncated 6 bytes)","reader":"xxxx...(truncated 996 bytes)",` +
+		`"text":"192....(truncated 7 bytes)","embedded":"...(truncated 7 bytes)",` +
+		`"addr":"10.0...(truncated 4 bytes)"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if !json.Valid([]byte(got)) {
+		t.Errorf("invalid JSON: %s", got)
+	}
+
+	// Embedded objects are kept intact as long as they fit
+	got = string(obj.Reset().MaxStringSize(7, "").ObjectString("embedded").Int("a", 1).EndObjectString().Build())
+	if want := `{"embedded":"{\"a\":1}"}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestObject_MaxSizeStreaming(t *testing.T) {
+	t.Parallel()
+
+	// Strings that don't fit in the budget are never copied into the buffer
+	obj := fson.NewObject(make([]byte, 0, 64)).MaxSize(32)
+	obj.String("a", "short").ReadStringFrom("big", strings.NewReader(strings.Repeat("x", 1<<20)))
+	w := obj.StringWriter("writer")
+	for range 1024 {
+		_, _ = w.Write([]byte(strings.Repeat("y", 1024)))
+	}
+	_ = w.Close()
+	got := string(obj.String("huge", strings.Repeat("z", 1<<20)).Build())
+
+	want := `{"a":"short","_truncated":true}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if obj.Cap() > 1024 {
+		t.Errorf("expected the buffer to stay small, got a capacity of %d", obj.Cap())
+	}
+
+	// Neither is binary data streamed with a Base64Writer
+	obj = fson.NewObject(make([]byte, 0, 64)).MaxSize(32)
+	b := obj.String("a", "short").Base64Writer("attachment", base64.StdEncoding)
+	chunk := bytes.Repeat([]byte{0xff}, 1000)
+	for range 1024 {
+		_, _ = b.Write(chunk)
+	}
+	_ = b.Close()
+	if got := string(obj.Build()); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if obj.Cap() > 1024 {
+		t.Errorf("expected the buffer to stay small, got a capacity of %d", obj.Cap())
+	}
+}
+
+func TestObject_BuildPartial(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	obj := fson.NewObject(buf.Bytes())
+	obj.Object("request").String("method", "GET").Array("headers").StringValue("a").StartObject().Key(`dang"ling\`)
+	got := string(obj.BuildPartial())
+	want := `{"request":{"method":"GET","headers":["a",{}]}}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// Nothing to close
+	obj.Reset().Int("a", 1)
+	got = string(obj.BuildPartial())
+	want = `{"a":1}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// Open writers and object strings are closed as well
+	obj.Reset()
+	_, _ = obj.ObjectString("inner").Key("w").StringWriterValue().Write([]byte("partial"))
+	got = string(obj.BuildPartial())
+	want = `{"inner":"{\"w\":\"partial\"}"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// Usable from a deferred recover
+	encode := func() (out []byte) {
+		obj.Reset()
+		defer func() {
+			if r := recover(); r != nil {
+				out = obj.CloseAll().String("panic", fmt.Sprint(r)).Build()
+			}
+		}()
+		obj.Object("user").Key("name")
+		panic("boom")
+	}
+	got = string(encode())
+	want = `{"user":{},"panic":"boom"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestNewFixedObject(t *testing.T) {
+	t.Parallel()
+
+	var arr [32]byte
+	obj := fson.NewFixedObject(arr[:])
+
+	got, err := obj.String("a", "fits").BuildErr()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"a":"fits"}`; string(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if &got[0] != &arr[0] {
+		t.Errorf("expected the result to use the provided buffer")
+	}
+
+	// Overflowing marks the object, Build returns nil and later writes are discarded
+	obj.Reset()
+	obj.String("a", "fits").Object("b").String("c", strings.Repeat("x", 64)).EndObject().Int("d", 1)
+	if !obj.Overflowed() {
+		t.Errorf("expected the object to overflow")
+	}
+	if b := obj.Build(); b != nil {
+		t.Errorf("expected nil, got %s", b)
+	}
+	if _, err := obj.BuildErr(); !errors.Is(err, fson.ErrBufferFull) {
+		t.Errorf("expected ErrBufferFull, got %v", err)
+	}
+	if obj.Cap() != len(arr) {
+		t.Errorf("expected capacity %d, got %d", len(arr), obj.Cap())
+	}
+
+	// Reading stops once the buffer is full
+	obj.Reset().ReadStringFrom("r", strings.NewReader(strings.Repeat("x", 64)))
+	if _, err := obj.BuildErr(); !errors.Is(err, fson.ErrBufferFull) {
+		t.Errorf("expected ErrBufferFull, got %v", err)
+	}
+
+	// Rolling back to before the overflow discards it along with the writes that didn't fit
+	obj.Reset().String("a", "fits")
+	sp := obj.Savepoint()
+	obj.String("big", strings.Repeat("x", 64))
+	if !obj.Overflowed() {
+		t.Errorf("expected the object to overflow")
+	}
+	got, err = obj.Rollback(sp).Int("b", 1).BuildErr()
+	if err != nil || obj.Overflowed() || string(got) != `{"a":"fits","b":1}` {
+		t.Errorf(`expected {"a":"fits","b":1}, got %s (%v, overflowed %t)`, got, err, obj.Overflowed())
+	}
+
+	// Reset clears the overflow
+	obj.Reset()
+	if obj.Overflowed() {
+		t.Errorf("expected Reset to clear the overflow")
+	}
+	got, err = obj.Int("n", 1).BuildErr()
+	if err != nil || string(got) != `{"n":1}` {
+		t.Errorf("expected {\"n\":1}, got %s (%v)", got, err)
+	}
+}
+
+func TestNewFixedObjectArray(t *testing.T) {
+	t.Parallel()
+
+	// A document that fills the array exactly still fits
+	var arr [64]byte
+	want := `{"a":"` + strings.Repeat("x", 48) + `","b":[1]}`
+	got, err := fson.NewFixedObjectArray(&arr).String("a", strings.Repeat("x", 48)).Ints("b", []int{1}).BuildErr()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != want || len(got) != len(arr) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if &got[0] != &arr[0] {
+		t.Errorf("expected the result to use the provided array")
+	}
+}
+
+func TestNewFixedObject_OverflowNoAllocs(t *testing.T) {
+	var arr [64]byte
+	obj := fson.NewFixedObjectArray(&arr)
+
+	long := strings.Repeat("x\n", 64)
+	longBytes := []byte(long)
+	newlines := strings.Repeat("\n", 16)
+	reader := strings.NewReader(long)
+	u, _ := url.Parse("https://example.com/" + strings.Repeat("x", 64))
+	fragment := fson.NewObject(nil).String("long", long).BuildFragment()
+	merged := []byte(`{"long":"` + strings.Repeat("x", 128) + `"}`)
+
+	// Writes that don't fit must be rejected before they are appended, without reallocating the buffer
+	tests := map[string]func(){
+		"key":     func() { obj.Key(long) },
+		"string":  func() { obj.String("s", long) },
+		"strings": func() { obj.Strings("s", []string{"a", long}) },
+		"ints":    func() { obj.Ints64("i", []int64{math.MinInt64, math.MinInt64, math.MinInt64}) },
+		"float":   func() { obj.Float64("f", math.MaxFloat64) },
+		"times":   func() { obj.TimesRFC3339Nano("t", []time.Time{{}, {}}) },
+		"url":     func() { obj.URL("u", u) },
+		"object":  func() { obj.ObjectString("o").String("s", newlines).EndObjectString() },
+		"writer": func() {
+			w := obj.StringWriter("w")
+			_, _ = w.Write(longBytes)
+			_ = w.Close()
+		},
+		"base64": func() {
+			w := obj.Base64Writer("b", base64.StdEncoding)
+			_, _ = w.Write(longBytes)
+			_ = w.Close()
+		},
+		"reader": func() {
+			reader.Reset(long)
+			obj.ReadStringFrom("r", reader)
+		},
+		"fragment": func() { obj.Fragment(fragment) },
+		"merge":    func() { obj.MergeObject(merged) },
+	}
+	for name, fn := range tests {
+		allocs := testing.AllocsPerRun(100, func() {
+			obj.Reset().Int("n", 1)
+			fn()
+			result = obj.Build()
+		})
+		if allocs != 0 {
+			t.Errorf("%s: expected no allocations, got %v", name, allocs)
+		}
+		if !obj.Overflowed() || result != nil {
+			t.Errorf("%s: expected the object to overflow, got %s", name, result)
+		}
+		if obj.Cap() != len(arr) {
+			t.Errorf("%s: expected capacity %d, got %d", name, len(arr), obj.Cap())
+		}
+	}
+}
+
+// addRequestFields is a helper like the ones found in real code bases, it is kept out of line so the
+// benchmarks below verify that passing an Object to a function doesn't make it escape.
+//
+//go:noinline
+func addRequestFields(o *fson.Object, method, path string, status int) {
+	o.Object("request").String("method", method).String("path", path).Int("status", status).EndObject()
+}
+
+// event embeds an Object by value, like a logger entry would.
+type event struct {
+	obj   fson.Object
+	level string
+}
+
+//go:noinline
+func (e *event) encode(buf []byte, msg string) []byte {
+	e.obj.Init(buf).String("level", e.level).String("msg", msg)
+	addRequestFields(&e.obj, "GET", "/", 200)
+	return e.obj.Build()
+}
+
+func TestObject_InitNoAllocs(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+	var arr [64]byte
+
+	tests := map[string]func(){
+		"NewObject": func() {
+			result = fson.NewObject(buf).String("msg", "hello").Build()
+		},
+		"Init": func() {
+			var obj fson.Object
+			result = obj.Init(buf).String("msg", "hello").Build()
+		},
+		"helper": func() {
+			var obj fson.Object
+			obj.Init(buf).String("msg", "hello")
+			addRequestFields(&obj, "GET", "/", 200)
+			result = obj.Build()
+		},
+		"embedded": func() {
+			e := event{level: "info"}
+			result = e.encode(buf, "hello")
+		},
+		"fixed": func() {
+			var obj fson.Object
+			result, _ = obj.InitFixed(buf).String("msg", "hello").BuildErr()
+		},
+		"fixed array": func() {
+			result, _ = fson.NewFixedObjectArray(&arr).String("msg", "hello").Int("n", 1).BuildErr()
+		},
+		"partial": func() {
+			var obj fson.Object
+			result = obj.Init(buf).Object("a").Key("b").BuildPartial()
+		},
+	}
+	for name, fn := range tests {
+		if allocs := testing.AllocsPerRun(100, fn); allocs != 0 {
+			t.Errorf("%s: expected no allocations, got %v", name, allocs)
+		}
+	}
+
+	var obj fson.Object
+	got := string(obj.Init(buf).String("msg", "hello").Build())
+	if want := `{"msg":"hello"}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// Deeply nested documents still work once the inline container stack is exhausted
+	obj.Init(buf).Key("a")
+	for range 20 {
+		obj.StartArray()
+	}
+	sp := obj.Savepoint()
+	obj.IntValue(1)
+	for range 20 {
+		obj.EndArray()
+	}
+	obj.Rollback(sp).IntValue(2)
+	got = string(obj.BuildPartial())
+	if want := `{"a":` + strings.Repeat("[", 20) + "2" + strings.Repeat("]", 20) + "}"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestObject_Builder(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	obj := fson.NewObject(buf.Bytes())
+	obj.Builder().
+		String("name", "John").
+		Bool("admin", true).
+		Int("age", 30).
+		Int64("id", -1).
+		Uint64("uid", 1).
+		Float64("score", 1.5).
+		Time("created", ts, time.DateOnly).
+		Null("deleted").
+		Any("any", []int{1}).
+		Key("status").Int(200).
+		Array("tags", func(a fson.ArrayBuilder) {
+			a.String("a").Bool(false).Int(1).Int64(2).Uint64(3).Float64(4.5).
+				Time(ts, time.DateOnly).Null().Any("b").
+				Value().String("c").
+				Object(func(b fson.ObjectBuilder) { b.Int("x", 1) }).
+				Array(func(a fson.ArrayBuilder) {})
+		}).
+		Object("address", func(b fson.ObjectBuilder) {
+			b.String("city", "Ghent").Object("geo", func(fson.ObjectBuilder) {})
+			obj.Strings("lines", []string{"Main St"}) // not covered by the typed view
+		})
+
+	got := string(obj.Build())
+	want := `{"name":"John","admin":true,"age":30,"id":-1,"uid":1,"score":1.5,"created":"2025-01-02",` +
+		`"deleted":null,"any":[1],"status":200,` +
+		`"tags":["a",false,1,2,3,4.5,"2025-01-02",null,"b","c",{"x":1},[]],` +
+		`"address":{"city":"Ghent","geo":{},"lines":["Main St"]}}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: `{"user":"john"}`, want: `{"user":"john","request_id":"abc"}`},
+		{in: "{\"user\":\"john\" }\n", want: `{"user":"john","request_id":"abc"}`},
+		{in: `{}`, want: `{"request_id":"abc"}`},
+		{in: ` { } `, want: ` {"request_id":"abc"}`},
+	}
+	for _, tt := range tests {
+		obj, err := fson.Reopen([]byte(tt.in))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if got := string(obj.String("request_id", "abc").Build()); got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.in, tt.want, got)
+		}
+	}
+
+	// A freshly built object can be reopened
+	b := fson.NewObject(nil).Object("a").Int("b", 1).EndObject().Build()
+	obj, err := fson.Reopen(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(obj.Int("c", 2).Build()), `{"a":{"b":1},"c":2}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	for _, in := range []string{``, `{`, `}`, `[]`, `{"a":1`, `"{}"`, `{"a":[1]`, `{"a":{"b":1}`, `{"a":"}`, `{"a":1},{"b":2}`} {
+		if _, err := fson.Reopen([]byte(in)); !errors.Is(err, fson.ErrNotObject) {
+			t.Errorf("%q: expected ErrNotObject, got %v", in, err)
+		}
+	}
+}
+
+func TestObject_MergeObject(t *testing.T) {
+	t.Parallel()
+
+	buf := buffPool.Get()
+	defer buffPool.Put(buf)
+
+	raw := []byte(` { "user" : "eve", "ip":"10.0.0.1" ,"tags":[1, 2], "\u0061":{"b":null}} `)
+	tests := []struct {
+		strategy fson.MergeStrategy
+		want     string
+	}{
+		{
+			strategy: fson.MergeKeepBoth,
+			want:     `{"a":1,"user":"john","n":{"user":"x"},"user":"eve","ip":"10.0.0.1","tags":[1, 2],"\u0061":{"b":null}}`,
+		},
+		{
+			strategy: fson.MergeKeepExisting,
+			want:     `{"a":1,"user":"john","n":{"user":"x"},"ip":"10.0.0.1","tags":[1, 2]}`,
+		},
+		{
+			strategy: fson.MergeKeepIncoming,
+			want:     `{"n":{"user":"x"},"user":"eve","ip":"10.0.0.1","tags":[1, 2],"\u0061":{"b":null}}`,
+		},
+	}
+	for _, tt := range tests {
+		obj := fson.NewObject(buf.Bytes()).MergeDuplicates(tt.strategy)
+		got := string(obj.Int("a", 1).String("user", "john").Object("n").String("user", "x").EndObject().
+			MergeObject(raw).Build())
+		if got != tt.want {
+			t.Errorf("strategy %d: expected %s, got %s", tt.strategy, tt.want, got)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Errorf("strategy %d: invalid JSON: %s", tt.strategy, got)
+		}
+		if obj.Err() != nil {
+			t.Errorf("strategy %d: unexpected error: %v", tt.strategy, obj.Err())
+		}
+	}
+
+	// Merging into nested and empty objects
+	obj := fson.NewObject(buf.Bytes()).MergeDuplicates(fson.MergeKeepIncoming)
+	got := string(obj.MergeObject([]byte(`{}`)).Object("meta").MergeObject([]byte(`{"a":1}`)).EndObject().
+		ObjectString("s").MergeObject([]byte(`{"b":"c"}`)).EndObjectString().
+		Build())
+	want := `{"meta":{"a":1},"s":"{\"b\":\"c\"}"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// Invalid input is rejected without writing anything
+	for _, in := range []string{``, `[]`, `{`, `{"a"}`, `{"a":1,}`, `{"a":01}`, `{"a":1}{}`, `{"a":tru}`,
+		`{"a":"\x"}`, "{\"a\":\"\x01\"}", "{\"a\":\"\xff\"}", `{"a":[1,]}`, `{a:1}`, `{"a":-}`, `{"a":1.}`,
+		`{"a":1e}`, `{"a":"\u12"}`, strings.Repeat(`{"a":`, 20000) + "1" + strings.Repeat(`}`, 20000)} {
+		obj.Reset()
+		got := string(obj.Int("x", 1).MergeObject([]byte(in)).Build())
+		if got != `{"x":1}` {
+			t.Errorf("%q: expected nothing to be merged, got %s", in, got)
+		}
+		if !errors.Is(obj.Err(), fson.ErrNotObject) {
+			t.Errorf("%q: expected ErrNotObject, got %v", in, obj.Err())
+		}
+	}
+
+	// Valid input in all its forms is accepted
+	for _, in := range []string{`{"a":[true,false,null,-0,1.5e+3,2E-2,"\"\\\/\b\f\n\r\t\u00e9"],"b":{},"c":[]}`,
+		"\t{\r\n}\n", `{"😀":"é"}`} {
+		obj.Reset()
+		if !json.Valid(obj.MergeObject([]byte(in)).Build()) || obj.Err() != nil {
+			t.Errorf("%q: expected the object to be merged, got %s (%v)", in, obj.Build(), obj.Err())
+		}
+	}
+
+	// Replaced members are only removed once the merged members are known to fit in the budget
+	obj = fson.NewObject(buf.Bytes()).MaxSize(40).MergeDuplicates(fson.MergeKeepIncoming)
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{in: `{"user":"eve","big":"xxxxxxxxxxxxxxxxxxxxxxxxx"}`, want: `{"n":1,"user":"e","_truncated":true}`},
+		{in: `{"user":"eve","id":1}`, want: `{"n":1,"user":"eve","id":1,"_truncated":true}`},
+	} {
+		got := string(obj.Reset().Int("n", 1).String("user", "e").MergeObject([]byte(tt.in)).
+			String("big", "xxxxxxxxxxxxxxxxx").Build())
+		if got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.in, tt.want, got)
+		}
+	}
+
+	// Merged values are subject to the depth and string limits
+	obj = fson.NewObject(buf.Bytes()).MaxDepth(3, fson.DefaultDepthPlaceholder).MaxStringSize(3, "…%d")
+	got = string(obj.Object("a").
+		MergeObject([]byte(`{"s":"h\u00e9llo","d":{"e":[1,{"f":2}], "g" : [ ]},"n":[ "abcd" ,1]}`)).
+		EndObject().Build())
+	want = `{"a":{"s":"h\u00e9…3","d":{"e":"<max depth>","g":"<max depth>"},"n":["abc…1",1]}}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	obj.Reset().MaxDepth(2, "").MaxStringSize(0, "")
+	if got, want := string(obj.MergeObject([]byte(`{"a":{"b":1},"c":[]}`)).Build()), `{"a":{"b":1},"c":[]}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got, want := string(obj.Reset().Object("x").MergeObject([]byte(`{"a":{"b":1},"c":2}`)).EndObject().Build()),
+		`{"x":{"a":null,"c":2}}`; got != want || !errors.Is(obj.Err(), fson.ErrMaxDepth) {
+		t.Errorf("expected %s and ErrMaxDepth, got %s (%v)", want, got, obj.Err())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected MergeObject to panic inside an array")
+		}
+	}()
+	obj.Reset().Array("a").MergeObject([]byte(`{}`))
+}
+
+func TestObject_Fragment(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+
+	base := fson.NewObject(nil).String("service", "api").String("env", "prod").BuildFragment()
+	req := fson.NewObject(nil).Fragment(base).Int("user_id", 7).BuildFragment()
+	if got, want := req.String(), `"service":"api","env":"prod","user_id":7`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if req.Len() != len(req.String())+1 {
+		t.Errorf("expected length %d, got %d", len(req.String())+1, req.Len())
+	}
+
+	obj := fson.NewObject(buf)
+	got := string(obj.Fragment(req).Object("nested").Fragment(base).EndObject().Fragment(fson.Fragment{}).Build())
+	want := `{"service":"api","env":"prod","user_id":7,"nested":{"service":"api","env":"prod"}}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// Building a fragment from an empty object or reusing the Object doesn't affect it
+	if f := obj.Reset().BuildFragment(); f.Len() != 0 || f.String() != "" {
+		t.Errorf("expected an empty fragment, got %q", f.String())
+	}
+	obj.String("service", "overwritten")
+	if got := string(fson.NewObject(buf).Fragment(base).Build()); got != `{"service":"api","env":"prod"}` {
+		t.Errorf("expected the fragment to be unchanged, got %s", got)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		result = obj.Reset().Fragment(req).String("msg", "hello").Build()
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+
+	// Fragments can be shared between goroutines
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := string(fson.NewObject(nil).Fragment(req).Build()); got != `{`+req.String()+`}` {
+				t.Errorf("unexpected result %s", got)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestObject_BuildFragmentMaxSize(t *testing.T) {
+	t.Parallel()
+
+	// Members over the budget are dropped before the fragment is taken, and the fragment says so
+	f := fson.NewObject(nil).MaxSize(10).String("a", "1").String("b", strings.Repeat("x", 30)).BuildFragment()
+	if got, want := f.String(), `"a":"1","_truncated":true`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got, want := string(fson.NewObject(nil).Fragment(f).Int("c", 1).Build()), `{"a":"1","_truncated":true,"c":1}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	f = fson.NewObject(nil).MaxSize(10).String("a", "1").BuildFragment()
+	if got, want := f.String(), `"a":"1"`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestObject_BuildFragmentMisuse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		fn   func(*fson.Object)
+	}{
+		{name: "after Build", fn: func(o *fson.Object) { o.String("svc", "api").Build() }},
+		{name: "empty Build", fn: func(o *fson.Object) { o.Build() }},
+		{name: "dangling key", fn: func(o *fson.Object) { o.String("svc", "api").Key("msg") }},
+		{name: "open object", fn: func(o *fson.Object) { o.Object("a") }},
+		{name: "open writer", fn: func(o *fson.Object) { _, _ = o.StringWriter("w").Write([]byte("x,")) }},
+		{name: "open base64 writer", fn: func(o *fson.Object) { o.Base64Writer("b", base64.StdEncoding) }},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected BuildFragment to panic", tt.name)
+				}
+			}()
+			obj := fson.NewObject(nil)
+			tt.fn(obj)
+			obj.BuildFragment()
+		}()
+	}
+}
+
 var result []byte
 
 func BenchmarkObject_BuildSimple(b *testing.B) {
@@ -893,3 +2790,75 @@ func BenchmarkJson_StdlibLarge(b *testing.B) {
 
 	result = r
 }
+
+func BenchmarkObject_Stack(b *testing.B) {
+	buf := make([]byte, 0, 1024*100)
+
+	obj := fson.NewObject(buf)
+	b.ReportAllocs()
+	for b.Loop() {
+		result = obj.Stack("stack", 0).Build()
+		obj.Reset()
+	}
+}
+
+func BenchmarkObject_TimeRFC3339Nano(b *testing.B) {
+	buf := make([]byte, 1024*100)
+	now := time.Now()
+
+	var r []byte
+	obj := fson.NewObject(buf)
+	for b.Loop() {
+		r = obj.TimeRFC3339Nano("time", now).Build()
+		obj.Reset()
+	}
+
+	result = r
+}
+
+func BenchmarkObject_TimeFormatRFC3339Nano(b *testing.B) {
+	buf := make([]byte, 1024*100)
+	now := time.Now()
+
+	var r []byte
+	obj := fson.NewObject(buf)
+	for b.Loop() {
+		r = obj.Time("time", now, time.RFC3339Nano).Build()
+		obj.Reset()
+	}
+
+	result = r
+}
+
+func BenchmarkObject_NewObjectPerEvent(b *testing.B) {
+	buf := make([]byte, 0, 1024)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		obj := fson.NewObject(buf)
+		addRequestFields(obj, "GET", "/", 200)
+		result = obj.String("msg", "hello").Build()
+	}
+}
+
+func BenchmarkObject_InitValue(b *testing.B) {
+	buf := make([]byte, 0, 1024)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		var obj fson.Object
+		obj.Init(buf)
+		addRequestFields(&obj, "GET", "/", 200)
+		result = obj.String("msg", "hello").Build()
+	}
+}
+
+func BenchmarkObject_InitEmbedded(b *testing.B) {
+	buf := make([]byte, 0, 1024)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		e := event{level: "info"}
+		result = e.encode(buf, "hello")
+	}
+}